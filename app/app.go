@@ -1,6 +1,8 @@
 package app
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"sync"
 
@@ -9,33 +11,59 @@ import (
 
 	. "github.com/tendermint/go-common"
 	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-events"
+	"github.com/tendermint/go-logger"
 	"github.com/tendermint/go-wire"
 	tmsp "github.com/tendermint/tmsp/types"
 )
 
 const version = "0.1"
 
+// signatures checked in CheckTx to remember for AppendTx
+const defaultSigCacheSize = 10000
+
 type LilVoterin struct {
 	mtx          sync.Mutex
 	state        *sm.State // consensus
 	blockState   *sm.State // mid-block state
 	mempoolState *sm.State // mempool
+
+	pruning sm.PruningPolicy // which saved heights to keep
+
+	// signatures checked in CheckTx, so AppendTx doesn't check them again.
+	// nil checks every signature
+	sigCache *types.SigCache
+
+	evsw events.EventSwitch // election events are fired on commit
 }
 
-func NewLilVoterin(db dbm.DB, nCandidates int) *LilVoterin {
-	state := sm.NewState(db, nCandidates)
+func NewLilVoterin(db dbm.DB) *LilVoterin {
+	state := sm.NewState(db)
+	evsw := events.NewEventSwitch()
+	evsw.Start()
 	return &LilVoterin{
 		state:        state,
 		blockState:   state.Copy(),
 		mempoolState: state.Copy(),
+		sigCache:     types.NewSigCache(defaultSigCacheSize),
+		evsw:         evsw,
 	}
 }
 
 func (app *LilVoterin) Load(genesisFile string) {
-	// TODO: better error check
-	if err := app.blockState.Load(); err != nil {
-		fmt.Println("Failed to load state", err)
-		fmt.Println("trying genesis")
+	// check the last commit finished before loading it
+	interrupted, err := app.blockState.CheckCommit()
+	if err != nil {
+		Exit("checking state: " + err.Error())
+	}
+	if interrupted >= 0 {
+		fmt.Printf("Commit of height %d was interrupted. Loading the last committed height\n", interrupted)
+	}
+
+	// only start from genesis if nothing was ever committed,
+	// so a state that fails to load can't silently reset the chain
+	if !app.blockState.IsCommitted() {
+		fmt.Println("No state found. Loading genesis")
 
 		// Load GenesisState for app
 		jsonBytes, err := ReadFile(genesisFile)
@@ -49,33 +77,146 @@ func (app *LilVoterin) Load(genesisFile string) {
 			Exit("parsing genesis JSON: " + err.Error())
 		}
 		fmt.Println("Gen:", genesisState)
+		if genesisState.Election != nil {
+			// register the candidates one by one to validate them
+			election := genesisState.Election
+			if err := election.ValidateRules(); err != nil {
+				Exit("loading genesis election: " + err.Error())
+			}
+			candidates := election.Candidates
+			election.Candidates = nil
+			for _, c := range candidates {
+				if _, err := election.AddCandidate(c); err != nil {
+					Exit("loading genesis candidates: " + err.Error())
+				}
+			}
+			app.setElection(election)
+		}
 		for _, account := range genesisState.Accounts {
 			if err := app.setAccount(account.PubKey, account.Account); err != nil {
 				Exit("loading genesis accounts: " + err.Error())
 			}
 		}
+		for _, account := range genesisState.MultisigAccounts {
+			if account.Multisig == nil {
+				Exit("loading genesis multisig accounts: missing multisig")
+			}
+			if err := account.Multisig.Validate(); err != nil {
+				Exit("loading genesis multisig accounts: " + err.Error())
+			}
+			if err := app.setAccount(account.Multisig.PubKey(), account.Account); err != nil {
+				Exit("loading genesis multisig accounts: " + err.Error())
+			}
+		}
+
+		// the genesis state is height 0
+		app.mtx.Lock()
+		defer app.mtx.Unlock()
+		app.commit(0)
+		return
 	}
-	app.Commit()
+
+	if err := app.blockState.Load(); err != nil {
+		Exit("loading state: " + err.Error())
+	}
+
+	// the state was loaded into the blockState
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	app.state = app.blockState.Copy()
+	app.resetStates()
+}
+
+// Returned by Info as JSON, so the last block the app committed can be read by tools
+type AppInfo struct {
+	Version          string `json:"version"`
+	LastBlockHeight  int    `json:"last_block_height"`
+	LastBlockAppHash []byte `json:"last_block_app_hash"`
 }
 
 // TMSP::Info
+// The pinned tmsp has no handshake, so tendermint doesn't read this.
+// Tendermint saves its state after the app commits, so after a crash in between
+// it executes the last block again, and BeginBlock rolls the app back for it
 func (app *LilVoterin) Info() string {
-	return "LilVoterin v" + version
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	info := AppInfo{
+		Version:          version,
+		LastBlockHeight:  app.state.GetHeight(),
+		LastBlockAppHash: app.state.GetAppHash(),
+	}
+	return string(wire.JSONBytes(info))
 }
 
 // TMSP::SetOption
+// Returns an empty log on success.
+// Options that affect consensus are only set before the first block.
+// After that, they can only be changed by an AdminTx
 func (app *LilVoterin) SetOption(key string, value string) (log string) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+
+	switch key {
+	case "log_level":
+		logger.SetLogLevel(value)
+		return ""
+	case "admin":
+		// add an admin account, for genesis-time setup
+		if app.state.GetHeight() > 0 {
+			return "Admins can only be added by an AdminTx after genesis"
+		}
+		// the hex of the pubkey's type byte and key
+		pubKeyBytes, err := hex.DecodeString(value)
+		if err != nil {
+			return Fmt("Invalid admin pubkey %s", value)
+		}
+		pubKey, err := types.PubKeyFromBytes(pubKeyBytes)
+		if err != nil || pubKey.IsZero() {
+			return Fmt("Invalid admin pubkey %s", value)
+		}
+		app.blockState.SetAccount(pubKey, &types.Account{Type: types.AccountTypeAdmin})
+		app.mempoolState.SetAccount(pubKey, &types.Account{Type: types.AccountTypeAdmin})
+		return ""
+	}
+
+	if !types.IsConsensusOption(key) {
+		return "Unknown option " + key
+	}
+	if app.state.GetHeight() > 0 {
+		return Fmt("Option %s affects consensus, so after genesis it can only be set by an AdminTx", key)
+	}
+	election := app.blockState.GetElection().Copy()
+	if err := election.SetOption(key, value); err != nil {
+		return err.Error()
+	}
+	app.blockState.SetElection(election)
+	app.mempoolState.SetElection(election.Copy())
 	return ""
 }
 
 // TMSP::AppendTx
+// Signatures already checked in CheckTx aren't checked again
 func (app *LilVoterin) AppendTx(txBytes []byte) (res tmsp.Result) {
-	return sm.ExecTxBytes(app.blockState, txBytes, true)
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return sm.ExecTxBytesWith(app.blockState, txBytes, true, app.verifier())
 }
 
 // TMSP::CheckTx
+// Locked like AppendTx, since Commit replaces the mempool state
+// and SetSigCache the cache
 func (app *LilVoterin) CheckTx(txBytes []byte) (res tmsp.Result) {
-	return sm.ExecTxBytes(app.mempoolState, txBytes, false)
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return sm.ExecTxBytesWith(app.mempoolState, txBytes, false, app.verifier())
+}
+
+func (app *LilVoterin) verifier() types.Verifier {
+	if app.sigCache == nil {
+		return types.VerifyNow
+	}
+	return app.sigCache
 }
 
 // TMSP::Query
@@ -90,48 +231,258 @@ func (app *LilVoterin) Query(query []byte) (res tmsp.Result) {
 		return tmsp.NewResultOK(value, "Success")*/
 }
 
+// TMSP::InitChain
+// Save the genesis validators, so a ValidatorTx can change them.
+// Only done once, before the first block.
+// Tendermint calls it again if its own state is reset,
+// and the app's validators must not be replaced by the genesis ones then
+func (app *LilVoterin) InitChain(validators []*tmsp.Validator) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	if app.state.GetHeight() > 0 {
+		log.Warn("Ignoring InitChain after the first block", "height", app.state.GetHeight())
+		return
+	}
+	if vals, err := app.blockState.GetValidators(); err != nil {
+		Exit("loading validators: " + err.Error())
+	} else if len(vals) > 0 {
+		log.Warn("Ignoring InitChain. The validators are already set")
+		return
+	}
+	vals := make([]types.Validator, 0, len(validators))
+	for _, v := range validators {
+		val, err := types.ValidatorFromTMSP(v)
+		if err != nil {
+			Exit("loading genesis validators: " + err.Error())
+		}
+		vals = append(vals, val)
+	}
+	app.blockState.InitValidators(vals)
+}
+
+// TMSP::BeginBlock
+// Runs the block hooks, like opening the election on schedule
+func (app *LilVoterin) BeginBlock(hash []byte, header *tmsp.Header) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	// the header has the app hash tendermint agreed on for the last block.
+	// if it's the one before ours, tendermint is executing the app's last block again.
+	// otherwise the state loaded isn't the chain's
+	if len(header.AppHash) > 0 && !bytes.Equal(header.AppHash, app.state.GetAppHash()) {
+		if err := app.rollback(header.AppHash); err != nil {
+			PanicCrisis(Fmt("App hash %X at height %d does not match the chain's %X: %v", app.state.GetAppHash(), app.state.GetHeight(), header.AppHash, err))
+		}
+	}
+	// the hooks and txs use the state's height,
+	// which differs from tendermint's if the app was started from a snapshot
+	if err := sm.BeginBlock(app.blockState); err != nil {
+		log.Error("Error in BeginBlock hook. The state wasn't changed by it", "height", app.blockState.GetBlockHeight(), "error", err)
+	}
+}
+
+// TMSP::EndBlock
+// Runs the block hooks, like closing the election on schedule,
+// and returns the validator changes made by txs in the block
+func (app *LilVoterin) EndBlock(height uint64) (diffs []*tmsp.Validator) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	if err := sm.EndBlock(app.blockState); err != nil {
+		log.Error("Error in EndBlock hook. The state wasn't changed by it", "height", app.blockState.GetBlockHeight(), "error", err)
+	}
+	for _, v := range app.blockState.PopValidatorDiffs() {
+		diffs = append(diffs, v.TMSP())
+	}
+	return diffs
+}
+
 // TMSP::Commit
 func (app *LilVoterin) Commit() (res tmsp.Result) {
 	app.mtx.Lock()
 	defer app.mtx.Unlock()
+	return app.commit(app.state.GetHeight() + 1)
+}
 
+func (app *LilVoterin) commit(height int) tmsp.Result {
 	// commit the state to disk
+	app.blockState.SetHeight(height)
 	hash, err := app.blockState.Save()
 	if err != nil {
 		// XXX
 		return tmsp.ErrInternalError.AppendLog(err.Error())
 	}
 
+	// the state is saved, so failing to prune is not fatal
+	if err := app.blockState.Prune(app.pruning); err != nil {
+		log.Warn("Failed to prune state", "height", height, "error", err)
+	}
+
+	events := app.blockState.PopEvents()
+
 	app.state = app.blockState.Copy()
+	app.resetStates()
+
+	for _, ev := range events {
+		app.evsw.FireEvent(ev.Name, ev.Data)
+	}
+
+	return tmsp.NewResultOK(hash, "")
+}
 
-	// reset the mempool and block state
+// roll the committed state back to the height with the app hash
+func (app *LilVoterin) rollback(appHash []byte) error {
+	state := app.state.Copy()
+	if err := state.Rollback(appHash); err != nil {
+		return err
+	}
+	log.Notice("Rolled back to execute the next block again", "height", state.GetHeight())
+	app.state = state
+	app.resetStates()
+	return nil
+}
+
+// reset the mempool and block state to the committed state
+func (app *LilVoterin) resetStates() {
 	app.mempoolState = app.state.Copy()
 	app.blockState = app.state.Copy()
+}
 
-	return tmsp.NewResultOK(hash, "")
+// Set the policy for pruning saved heights at each Commit
+func (app *LilVoterin) SetPruning(policy sm.PruningPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	app.pruning = policy
+	return nil
+}
+
+// Set how many signatures checked in CheckTx are remembered,
+// so AppendTx doesn't check them again. 0 checks every signature
+func (app *LilVoterin) SetSigCache(size int) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	if size > 0 {
+		app.sigCache = types.NewSigCache(size)
+	} else {
+		app.sigCache = nil
+	}
+}
+
+// Subscribe to the events in types/events.go.
+// The app rpc's websocket subscribes through it
+func (app *LilVoterin) EventSwitch() events.EventSwitch {
+	return app.evsw
 }
 
 //--------------------------------
 
+// Returns a copy of the state committed at the height,
+// or the latest state if height is 0
+func (app *LilVoterin) GetState(height int) (*sm.State, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	if height == 0 || height == app.state.GetHeight() {
+		return app.state.Copy(), nil
+	}
+	if height > app.state.GetHeight() {
+		return nil, fmt.Errorf("Height %d is after the latest height %d", height, app.state.GetHeight())
+	}
+	return app.state.LoadHeight(height)
+}
+
 func (app *LilVoterin) GetTally() *types.Tally {
 	app.mtx.Lock()
 	defer app.mtx.Unlock()
 	return app.state.GetTally()
 }
 
+// Returns the tally labeled with the registered candidates,
+// and the counts for write-ins
+func (app *LilVoterin) GetResults() ([]types.CandidateResult, []types.WriteInResult, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	writeIns, err := app.state.GetWriteIns()
+	if err != nil {
+		return nil, nil, err
+	}
+	return app.state.GetElection().Results(app.state.GetTally()), writeIns, nil
+}
+
+// Returns the outcome recorded when the election closed, if any
+func (app *LilVoterin) GetOutcome() (*types.Outcome, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return app.state.GetOutcome()
+}
+
 func (app *LilVoterin) GetAccount(pubKey types.PubKey) (*types.Account, error) {
 	app.mtx.Lock()
 	defer app.mtx.Unlock()
 	return app.state.GetAccount(pubKey)
 }
 
+// Returns the voter's direct delegate and the delegate whose
+// ballots would be counted for them, if the election closed now
+func (app *LilVoterin) GetDelegate(pubKey types.PubKey) (delegate, effective *types.PubKey, err error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	delegate, err = app.state.GetDelegate(pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	effective, err = app.state.GetEffectiveDelegate(pubKey)
+	return delegate, effective, err
+}
+
 // For testing - acts on the blockState and must call Commit() to take effect
 func (app *LilVoterin) setAccount(pubKey types.PubKey, acc *types.Account) error {
 	return app.blockState.SetAccount(pubKey, acc)
 }
 
-func (app *LilVoterin) GetAccounts() ([]*types.PubAccount, error) {
+// For testing - acts on the blockState and must call Commit() to take effect
+func (app *LilVoterin) setElection(election *types.Election) {
+	app.blockState.SetElection(election)
+}
+
+func (app *LilVoterin) GetProposal(id int) (*types.Proposal, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return app.state.GetProposal(id)
+}
+
+// Returns the proposals that can still be approved in the next block
+func (app *LilVoterin) GetPendingProposals() ([]*types.Proposal, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	proposals, err := app.state.GetProposals()
+	if err != nil {
+		return nil, err
+	}
+	var pending []*types.Proposal
+	for _, p := range proposals {
+		if p.IsPending(app.state.GetBlockHeight()) {
+			pending = append(pending, p)
+		}
+	}
+	return pending, nil
+}
+
+func (app *LilVoterin) GetValidators() ([]types.Validator, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return app.state.GetValidators()
+}
+
+// An empty status returns every account
+func (app *LilVoterin) GetAccounts(status types.AccountStatus) ([]*types.PubAccount, error) {
+	app.mtx.Lock()
+	defer app.mtx.Unlock()
+	return app.state.GetAccountsByStatus(status)
+}
+
+func (app *LilVoterin) GetAccountHistory(pubKey types.PubKey) (types.AccountHistory, error) {
 	app.mtx.Lock()
 	defer app.mtx.Unlock()
-	return app.state.GetAccounts()
+	return app.state.GetAccountHistory(pubKey)
 }
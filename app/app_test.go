@@ -1,13 +1,18 @@
 package app
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 	"time"
 
 	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
 	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-events"
+	"github.com/tendermint/go-wire"
 
+	sm "github.com/tendermint/lil-voterin/state"
 	"github.com/tendermint/lil-voterin/types"
 	tmsp "github.com/tendermint/tmsp/types"
 )
@@ -22,28 +27,47 @@ func makeTestTx(pub types.PubKey, nonce int) *types.VoteTx {
 	return &types.VoteTx{
 		Ballots: []types.Ballot{b1, b2},
 		Nonce:   []byte{byte(nonce)},
-		PubKey:  pub,
+		PubKey:  &pub,
+	}
+}
+
+// a vote tx with a single ballot
+func makeTestVoteTx(pub types.PubKey, nonce int, candidates ...types.Candidate) *types.VoteTx {
+	return &types.VoteTx{
+		Ballots: []types.Ballot{types.Ballot{Candidates: candidates, Source: RandStr(32)}},
+		Nonce:   []byte{byte(nonce)},
+		PubKey:  &pub,
 	}
 }
 
 func makeTestBallots() (types.Ballot, types.Ballot) {
-	b1 := types.Ballot{[]types.Candidate{0, 2, 3}, RandStr(32)}
-	b2 := types.Ballot{[]types.Candidate{0, 1}, RandStr(32)}
+	b1 := types.Ballot{Candidates: []types.Candidate{0, 2, 3}, Source: RandStr(32)}
+	b2 := types.Ballot{Candidates: []types.Candidate{0, 1}, Source: RandStr(32)}
 	return b1, b2
 }
 
 func newLilVoterin(nCandidates int) *LilVoterin {
 	db := dbm.NewDB("lil-voterin-app", "memdb", "")
-	return NewLilVoterin(db, nCandidates)
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nCandidates)})
+	return app
+}
+
+func makeTestCandidates(n int) []types.CandidateInfo {
+	candidates := make([]types.CandidateInfo, n)
+	for i := 0; i < n; i++ {
+		candidates[i] = types.CandidateInfo{Name: Fmt("candidate %d", i)}
+	}
+	return candidates
 }
 
-func expectFail(t *testing.T, r tmsp.Result) {
+func expectFail(t testing.TB, r tmsp.Result) {
 	if r.Code == 0 {
 		panic(Fmt("expected test to fail with bad sig. got code %v, log %s", r.Code, r.Log))
 	}
 }
 
-func expectPass(t *testing.T, r tmsp.Result) {
+func expectPass(t testing.TB, r tmsp.Result) {
 	if r.Code != 0 {
 		panic(Fmt("expected test to pass. got code %v, log %s", r.Code, r.Log))
 	}
@@ -284,9 +308,13 @@ func TestAdminTx(t *testing.T) {
 		expectPass(t, r)
 	}
 
-	// remove an old voter and ensure it cant vote
+	// suspend an old voter and ensure it cant vote
 	{
-		tx = types.MakeAdminTx(a1p, v1p, types.AccountTypeCorrupt, []byte{1})
+		tx = types.MakeAccountActionTx(a1p, types.AccountAction{
+			PubKey: v1p,
+			Action: types.AccountActionSuspend,
+			Reason: "test",
+		}, []byte{1})
 		tx.Sign(a1s)
 		r = app.AppendTx(types.JSONBytes(tx))
 		expectPass(t, r)
@@ -314,13 +342,89 @@ func TestAdminTx(t *testing.T) {
 		r = app.AppendTx(types.JSONBytes(tx))
 		expectPass(t, r)
 	}
+
+	// an account without the account is refused, not set
+	{
+		_, v4p, _ := types.NewAccount(types.AccountTypeVoter)
+		adminTx := types.MakeAdminTx(a1p, v4p, types.AccountTypeVoter, []byte{3})
+		adminTx.PubAccounts[0].Account = nil
+		adminTx.Sign(a1s)
+		expectFail(t, app.CheckTx(types.JSONBytes(adminTx)))
+		expectFail(t, app.AppendTx(types.JSONBytes(adminTx)))
+
+		// and by the state, for proposals made before it was checked
+		expectFail(t, sm.ExecAdminTx(app.blockState, adminTx, true))
+		app.Commit()
+		if _, err := app.state.GetAccount(v4p); err == nil {
+			t.Fatal("expected no account to be set")
+		}
+	}
 }
 
 //----------------------------------------------------------------------
-// test throughput
+// test roles
 
-func TestThroughput(t *testing.T) {
+func TestRoles(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	r1s, r1p, r1a := types.NewAccount(types.AccountTypeRegistrar)
+	o1s, o1p, o1a := types.NewAccount(types.AccountTypeOfficer)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	s1s, s1p, s1a := types.NewAccount(types.AccountTypeSuperAdmin)
+	app.setAccount(r1p, r1a)
+	app.setAccount(o1p, o1a)
+	app.setAccount(a1p, a1a)
+	app.setAccount(s1p, s1a)
+	app.Commit()
+
+	_, v1p, _ := types.NewAccount(types.AccountTypeVoter)
+	_, a2p, _ := types.NewAccount(types.AccountTypeAdmin)
+	_, s2p, _ := types.NewAccount(types.AccountTypeSuperAdmin)
+
+	for i, c := range []struct {
+		priv   crypto.PrivKey
+		signer types.PubKey
+		pub    types.PubKey
+		typ    types.AccountType
+		pass   bool
+	}{
+		{r1s, r1p, v1p, types.AccountTypeVoter, true},       // registrars add voters
+		{r1s, r1p, a2p, types.AccountTypeAdmin, false},      // but not admins
+		{r1s, r1p, a1p, types.AccountTypeCorrupt, false},    // or disable them
+		{o1s, o1p, v1p, types.AccountTypeCorrupt, false},    // officers don't manage accounts
+		{a1s, a1p, a2p, types.AccountTypeAdmin, true},       // admins add admins
+		{a1s, a1p, s2p, types.AccountTypeSuperAdmin, false}, // but not super-admins
+		{s1s, s1p, s2p, types.AccountTypeSuperAdmin, true},  // super-admins do
+		{a1s, a1p, s1p, types.AccountTypeCorrupt, false},    // and admins can't disable them
+	} {
+		tx := types.MakeAdminTx(c.signer, c.pub, c.typ, []byte{byte(i)})
+		tx.Sign(c.priv)
+		r := app.AppendTx(types.JSONBytes(tx))
+		if c.pass {
+			expectPass(t, r)
+		} else {
+			expectFail(t, r)
+		}
+	}
+
+	// registrars can't run the election, officers can
+	tx := &types.CloseTx{Nonce: []byte{100}, PubKey: r1p}
+	tx.Sign(r1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = &types.CloseTx{Nonce: []byte{100}, PubKey: o1p}
+	tx.Sign(o1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+}
+
+//----------------------------------------------------------------------
+// test replacing ballots
+
+func TestReplaceBallots(t *testing.T) {
 	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		ReplaceBallots: true,
+		Candidates:     makeTestCandidates(nTestCandidates),
+	})
 
 	priv1, pub1, acc1 := types.NewAccount(types.AccountTypeVoter)
 	priv2, pub2, acc2 := types.NewAccount(types.AccountTypeVoter)
@@ -328,35 +432,2045 @@ func TestThroughput(t *testing.T) {
 	app.setAccount(pub2, acc2)
 	app.Commit()
 
-	ntxs := 1000
-	nballots := 10
+	// both vote for {0, 2, 3} and {0, 1}
+	tx := makeTestTx(pub1, 0)
+	tx.Sign(priv1)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
 
-	tally := types.NewTally(nTestCandidates)
-	start := time.Now()
-	for i := 0; i < ntxs; i++ {
-		var tx *types.VoteTx
-		// split txs over two accounts
-		if i%2 == 0 {
-			tx = types.MakeVoteTx(tally, pub1, i, nTestCandidates, nballots)
-			tx.Sign(priv1)
-		} else {
-			tx = types.MakeVoteTx(tally, pub2, i, nTestCandidates, nballots)
-			tx.Sign(priv2)
+	tx = makeTestTx(pub2, 0)
+	tx.Sign(priv2)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{4, 2, 2, 2, 0})
+
+	// first voter changes their mind
+	tx = &types.VoteTx{
+		Ballots: []types.Ballot{types.Ballot{Candidates: []types.Candidate{4}, Source: RandStr(32)}},
+		Nonce:   []byte{1},
+		PubKey:  &pub1,
+	}
+	tx.Sign(priv1)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{2, 1, 1, 1, 1})
+
+	// and again, in the same block as the second voter
+	tx = makeTestTx(pub1, 2)
+	tx.Sign(priv1)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	tx = makeTestTx(pub2, 1)
+	tx.Sign(priv2)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{4, 2, 2, 2, 0})
+}
+
+func expectTally(t *testing.T, app *LilVoterin, expected []int64) {
+	tally := app.GetTally()
+	for i, c := range expected {
+		if tally.Counts[i] != c {
+			t.Fatalf("tallys don't match for index %d. got %d, expected %d", i, tally.Counts[i], c)
 		}
-		r := app.AppendTx(types.JSONBytes(tx))
-		if r.Code != 0 {
-			t.Fatalf("Transaction failed %v: %v", r, string(types.JSONBytes(tx)))
+	}
+}
+
+//----------------------------------------------------------------------
+// test candidate registry
+
+func TestCandidateRegistry(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	// candidate 5 isn't registered so the ballot isn't counted
+	var tx types.Tx
+	tx = makeTestVoteTx(v1p, 0, 5)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	expectTally(t, app, []int64{0, 0, 0, 0, 0})
+
+	// register it
+	adminTx := &types.AdminTx{
+		Candidates: []types.CandidateInfo{{Name: "newcomer", Description: "registered late"}},
+		Nonce:      []byte{0},
+		PubKey:     &a1p,
+	}
+	adminTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(adminTx)))
+
+	// names must be unique
+	adminTx = &types.AdminTx{
+		Candidates: []types.CandidateInfo{{Name: "candidate 0"}},
+		Nonce:      []byte{1},
+		PubKey:     &a1p,
+	}
+	adminTx.Sign(a1s)
+	r := app.AppendTx(types.JSONBytes(adminTx))
+	if r.Code != types.CodeTypeBadCandidate {
+		t.Fatalf("expected bad candidate. got code %v, log %s", r.Code, r.Log)
+	}
+
+	tx = makeTestVoteTx(v1p, 1, 5)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	expectTally(t, app, []int64{0, 0, 0, 0, 0, 1})
+
+	results, _, err := app.GetResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != nTestCandidates+1 {
+		t.Fatalf("expected %d results, got %d", nTestCandidates+1, len(results))
+	}
+	if results[5].Name != "newcomer" || results[5].Count != 1 {
+		t.Fatalf("expected 1 vote for newcomer, got %v", results[5])
+	}
+}
+
+//----------------------------------------------------------------------
+// test write-ins
+
+func makeTestWriteInTx(pub types.PubKey, nonce int, writeIn string) *types.VoteTx {
+	return &types.VoteTx{
+		Ballots: []types.Ballot{types.Ballot{Source: RandStr(32), WriteIn: writeIn}},
+		Nonce:   []byte{byte(nonce)},
+		PubKey:  &pub,
+	}
+}
+
+func TestWriteIns(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		AllowWriteIns: true,
+		Candidates:    makeTestCandidates(nTestCandidates),
+	})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	var tx types.Tx
+	for i, writeIn := range []string{" Dave  Smith", "dave smith", "Eve"} {
+		tx = makeTestWriteInTx(v1p, i, writeIn)
+		tx.Sign(v1s)
+		expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	}
+	app.Commit()
+
+	_, writeIns, err := app.GetResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(writeIns) != 2 || writeIns[0].Name != "dave smith" || writeIns[0].Count != 2 || writeIns[1].Count != 1 {
+		t.Fatalf("unexpected write-ins %v", writeIns)
+	}
+
+	// fold eve into candidate 1 and promote dave smith
+	adminTx := &types.AdminTx{
+		WriteIns: []types.WriteInAction{
+			{WriteIn: "eve", Into: "candidate 1"},
+			{WriteIn: "Dave Smith"},
+		},
+		Nonce:  []byte{0},
+		PubKey: &a1p,
+	}
+	adminTx.Sign(a1s)
+
+	// not until the election is closed
+	expectFail(t, app.AppendTx(types.JSONBytes(adminTx)))
+
+	tx = &types.CloseTx{Nonce: []byte{1}, PubKey: a1p}
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// a misspelt candidate isn't made into a write-in
+	badTx := &types.AdminTx{
+		WriteIns: []types.WriteInAction{{WriteIn: "eve", Into: "candidate one"}},
+		Nonce:    []byte{2},
+		PubKey:   &a1p,
+	}
+	badTx.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(badTx)))
+
+	expectPass(t, app.AppendTx(types.JSONBytes(adminTx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{0, 1, 0, 0, 0, 2})
+	results, writeIns, err := app.GetResults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(writeIns) != 0 {
+		t.Fatalf("expected no write-ins left, got %v", writeIns)
+	}
+	if results[5].Name != "dave smith" {
+		t.Fatalf("expected dave smith to be promoted, got %v", results[5])
+	}
+	// the outcome recorded at close had no winner
+	outcome, err := app.GetOutcome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !outcome.Valid || !outcome.HasWinner || outcome.Winner != 5 || outcome.WinnerVotes != 2 {
+		t.Fatalf("expected dave smith to win after the promotion, got %v", outcome)
+	}
+}
+
+//----------------------------------------------------------------------
+// test none of the above
+
+func TestNoneOfTheAboveRerun(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		RerunOnNoneOfTheAbove: true,
+		Candidates:            makeTestCandidates(nTestCandidates),
+	})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	nota := types.Ballot{Source: RandStr(32), Choice: types.BallotChoiceNoneOfTheAbove}
+	var tx types.Tx
+	tx = &types.VoteTx{
+		Ballots: []types.Ballot{nota, nota},
+		Nonce:   []byte{0},
+		PubKey:  &v1p,
+	}
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestVoteTx(v1p, 1, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// none of the above wins, so the election is re-run
+	tx = &types.CloseTx{Nonce: []byte{0}, PubKey: a1p}
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	tally := app.GetTally()
+	if tally.Ballots != 0 || tally.NoneOfTheAbove != 0 {
+		t.Fatalf("expected the tally to be reset, got %v", tally)
+	}
+	expectTally(t, app, []int64{0, 0, 0, 0, 0})
+
+	// voting is still open
+	tx = makeTestVoteTx(v1p, 2, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	tx = &types.CloseTx{Nonce: []byte{1}, PubKey: a1p}
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{1, 0, 0, 0, 0})
+	tx = makeTestVoteTx(v1p, 3, 0)
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+}
+
+func TestNoneOfTheAboveScheduledRerun(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		RerunOnNoneOfTheAbove: true,
+		CloseHeight:           3,
+		Candidates:            makeTestCandidates(nTestCandidates),
+	})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	nota := types.Ballot{Source: RandStr(32), Choice: types.BallotChoiceNoneOfTheAbove}
+	// none of the above wins the first run, which closes at 3.
+	// the rerun is closed 3 blocks later
+	for h := 2; h <= 6; h++ {
+		app.BeginBlock(nil, &tmsp.Header{Height: uint64(h)})
+		switch h {
+		case 2:
+			tx := &types.VoteTx{
+				Ballots: []types.Ballot{nota},
+				Nonce:   []byte{byte(h)},
+				PubKey:  &v1p,
+			}
+			tx.Sign(v1s)
+			expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+		case 4:
+			tx := makeTestVoteTx(v1p, h, 1)
+			tx.Sign(v1s)
+			expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+		}
+		app.EndBlock(uint64(h))
+		app.Commit()
+
+		if election := app.state.GetElection(); h < 6 && election.Closed {
+			t.Fatalf("expected the election to be open after %d", h)
+		} else if h == 3 && (election.Reruns != 1 || election.CloseHeight != 6) {
+			t.Fatalf("expected the rerun to close at 6, got %v", election)
 		}
 	}
 
-	fmt.Printf("took %v. %v ballots/sec\n", time.Since(start), float64(ntxs*nballots)/float64(time.Since(start).Seconds()))
+	election := app.state.GetElection()
+	if !election.Closed || election.ClosedHeight != 6 {
+		t.Fatalf("expected the rerun to be closed at 6, got %v", election)
+	}
+	outcome, err := app.GetOutcome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome == nil || !outcome.HasWinner || outcome.Winner != 1 {
+		t.Fatalf("expected candidate 1 to win the rerun, got %v", outcome)
+	}
+}
+
+//----------------------------------------------------------------------
+// test outcome
 
+func TestOutcome(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		Candidates: makeTestCandidates(nTestCandidates),
+		MinTurnout: types.Fraction{1, 2},
+	})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	v3s, v3p, v3a := types.NewAccount(types.AccountTypeVoter)
+	v4s, v4p, v4a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.setAccount(v3p, v3a)
+	app.setAccount(v4p, v4a)
+	app.setAccount(a1p, a1a)
 	app.Commit()
 
-	appTally := app.GetTally()
-	for i, c := range tally.Counts {
-		if c != appTally.Counts[i] {
-			t.Fatalf("tallys don't match for index %d. got %d, expected %d", i, appTally.Counts[i], c)
+	// two of three voters vote, one abstains
+	var tx types.Tx
+	tx = makeTestVoteTx(v1p, 0, 2)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = &types.VoteTx{
+		Ballots: []types.Ballot{types.Ballot{Source: RandStr(32), Choice: types.BallotChoiceAbstain}},
+		Nonce:   []byte{0},
+		PubKey:  &v2p,
+	}
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// v3's only ballot isn't counted, so v3 hasn't voted
+	tx = makeTestVoteTx(v3p, 0, types.Candidate(nTestCandidates))
+	tx.Sign(v3s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// v4 votes, but is suspended before the close
+	tx = makeTestVoteTx(v4p, 0, 2)
+	tx.Sign(v4s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = types.MakeAccountActionTx(a1p, types.AccountAction{PubKey: v4p, Action: types.AccountActionSuspend, Reason: "test"}, []byte{0})
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	outcome, err := app.GetOutcome()
+	if err != nil || outcome != nil {
+		t.Fatalf("expected no outcome before close, got %v, %v", outcome, err)
+	}
+
+	// a voter added in the same block as the close is eligible
+	_, v5p, _ := types.NewAccount(types.AccountTypeVoter)
+	tx = types.MakeAdminTx(a1p, v5p, types.AccountTypeVoter, []byte{1})
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	tx = &types.CloseTx{Nonce: []byte{2}, PubKey: a1p}
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	outcome, err = app.GetOutcome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !outcome.Valid || outcome.Winner != 2 || outcome.Turnout != 2 || outcome.Eligible != 4 {
+		t.Fatalf("unexpected outcome %v", outcome)
+	}
+	if outcome.ClosedBy != a1p {
+		t.Fatalf("expected outcome to be closed by %X, got %X", a1p, outcome.ClosedBy)
+	}
+}
+
+//----------------------------------------------------------------------
+// test delegation
+
+func makeTestDelegateTx(pub types.PubKey, delegate *types.PubKey, nonce int) *types.DelegateTx {
+	return &types.DelegateTx{
+		Delegate: delegate,
+		Nonce:    []byte{byte(nonce)},
+		PubKey:   pub,
+	}
+}
+
+func TestDelegation(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		ReplaceBallots: true,
+		Candidates:     makeTestCandidates(nTestCandidates),
+	})
+
+	as, ap, aa := types.NewAccount(types.AccountTypeVoter)
+	bs, bp, ba := types.NewAccount(types.AccountTypeVoter)
+	cs, cp, ca := types.NewAccount(types.AccountTypeVoter)
+	ds, dp, da := types.NewAccount(types.AccountTypeVoter)
+	es, ep, ea := types.NewAccount(types.AccountTypeVoter)
+	fs, fp, fa := types.NewAccount(types.AccountTypeVoter)
+	admins, adminp, admina := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(ap, aa)
+	app.setAccount(bp, ba)
+	app.setAccount(cp, ca)
+	app.setAccount(dp, da)
+	app.setAccount(ep, ea)
+	app.setAccount(fp, fa)
+	app.setAccount(adminp, admina)
+	app.Commit()
+
+	var tx types.Tx
+
+	// a -> b -> c
+	tx = makeTestDelegateTx(ap, &bp, 0)
+	tx.Sign(as)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestDelegateTx(bp, &cp, 0)
+	tx.Sign(bs)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// c -> a is a cycle
+	tx = makeTestDelegateTx(cp, &ap, 0)
+	tx.Sign(cs)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// d -> c, but d votes directly
+	tx = makeTestDelegateTx(dp, &cp, 0)
+	tx.Sign(ds)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestVoteTx(dp, 1, 1)
+	tx.Sign(ds)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// e -> c and f -> c, but e is suspended and f can no longer vote
+	tx = makeTestDelegateTx(ep, &cp, 0)
+	tx.Sign(es)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestDelegateTx(fp, &cp, 0)
+	tx.Sign(fs)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = types.MakeAccountActionTx(adminp, types.AccountAction{PubKey: ep, Action: types.AccountActionSuspend, Reason: "test"}, []byte{0})
+	tx.Sign(admins)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = types.MakeAdminTx(adminp, fp, types.AccountTypeAuditor, []byte{1})
+	tx.Sign(admins)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// c votes for a, b, and c
+	tx = makeTestVoteTx(cp, 1, 4)
+	tx.Sign(cs)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	delegate, effective, err := app.GetDelegate(ap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delegate == nil || *delegate != bp {
+		t.Fatalf("expected delegate %X, got %v", bp, delegate)
+	}
+	if effective == nil || *effective != cp {
+		t.Fatalf("expected effective delegate %X, got %v", cp, effective)
+	}
+
+	// delegated votes are counted on close
+	expectTally(t, app, []int64{0, 1, 0, 0, 1})
+
+	tx = &types.CloseTx{Nonce: []byte{2}, PubKey: adminp}
+	tx.Sign(admins)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	expectTally(t, app, []int64{0, 1, 0, 0, 3})
+
+	// no more votes
+	tx = makeTestVoteTx(ap, 1, 0)
+	tx.Sign(as)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+}
+
+//----------------------------------------------------------------------
+// test ballot quota
+
+func TestBallotQuota(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	v1a.BallotQuota = 3
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	// two ballots per tx
+	tx := makeTestTx(v1p, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	// only one left
+	tx = makeTestTx(v1p, 1)
+	tx.Sign(v1s)
+	r := app.AppendTx(types.JSONBytes(tx))
+	if r.Code != types.CodeTypeBallotQuotaExceeded {
+		t.Fatalf("expected quota exceeded. got code %v, log %s", r.Code, r.Log)
+	}
+
+	// admin raises the quota
+	adminTx := types.MakeAdminTx(a1p, v1p, types.AccountTypeVoter, []byte{0})
+	adminTx.PubAccounts[0].Account.BallotQuota = 4
+	adminTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(adminTx)))
+
+	tx = makeTestTx(v1p, 1)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// exhausted
+	tx = makeTestTx(v1p, 2)
+	tx.Sign(v1s)
+	r = app.AppendTx(types.JSONBytes(tx))
+	if r.Code != types.CodeTypeBallotQuotaExceeded {
+		t.Fatalf("expected quota exceeded. got code %v, log %s", r.Code, r.Log)
+	}
+}
+
+//----------------------------------------------------------------------
+// test historical state
+
+func TestStateAtHeight(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	// one vote for candidate i in block i+2
+	for i := 0; i < 3; i++ {
+		tx := makeTestVoteTx(v1p, i, types.Candidate(i))
+		tx.Sign(v1s)
+		expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+		app.Commit()
+	}
+
+	for height, expected := range map[int][]int64{
+		1: {0, 0, 0, 0, 0},
+		3: {1, 1, 0, 0, 0},
+		0: {1, 1, 1, 0, 0},
+	} {
+		state, err := app.GetState(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range expected {
+			if state.GetTally().Counts[i] != c {
+				t.Fatalf("tallys don't match at height %d for index %d. got %d, expected %d", height, i, state.GetTally().Counts[i], c)
+			}
+		}
+		if _, err := state.Proof(types.AccountKeyBytes(v1p)); err != nil {
+			t.Fatal(err)
 		}
 	}
+
+	if _, err := app.GetState(5); err == nil {
+		t.Fatal("expected error for state after the latest height")
+	}
+
+	// restart from the db
+	app = NewLilVoterin(db)
+	app.Load("")
+	state, err := app.GetState(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetHeight() != 4 {
+		t.Fatalf("expected height 4 after reload, got %d", state.GetHeight())
+	}
+	expectTally(t, app, []int64{1, 1, 1, 0, 0})
+}
+
+//----------------------------------------------------------------------
+// test info
+
+func TestInfo(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+
+	if info, expected := app.Info(), string(wire.JSONBytes(AppInfo{Version: version})); info != expected {
+		t.Fatalf("expected info %q before a block, got %q", expected, info)
+	}
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+	tx := makeTestVoteTx(v1p, 1, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	res := app.Commit()
+
+	info := app.Info()
+	if expected := string(wire.JSONBytes(AppInfo{version, 2, res.Data})); info != expected {
+		t.Fatalf("expected info %q, got %q", expected, info)
+	}
+
+	// the height and app hash are the same after a restart
+	app = NewLilVoterin(db)
+	app.Load("")
+	if info2 := app.Info(); info2 != info {
+		t.Fatalf("expected info %q after reload, got %q", info, info2)
+	}
+}
+
+//----------------------------------------------------------------------
+// test options
+
+func TestSetOption(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	a1s, a1p, _ := types.NewAccount(types.AccountTypeAdmin)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	for key, value := range map[string]string{
+		"admin":                     Fmt("%X", a1p.Bytes()),
+		types.OptionMaxBallotsPerTx: "1",
+		"log_level":                 "info",
+	} {
+		if log := app.SetOption(key, value); log != "" {
+			t.Fatalf("setting %s: %s", key, log)
+		}
+	}
+	for key, value := range map[string]string{
+		"nope":                      "1",
+		types.OptionMaxBallotsPerTx: "-1",
+		types.OptionStrictBallots:   "maybe",
+		types.OptionMinTurnout:      "1/0",
+		types.OptionMinWinningShare: "3/2",
+		"admin":                     "abc",
+	} {
+		if log := app.SetOption(key, value); log == "" {
+			t.Fatalf("expected an error setting %s to %s", key, value)
+		}
+	}
+	app.Commit()
+
+	// two ballots is too many
+	tx := makeTestTx(v1p, 1)
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// consensus options can't be set after genesis
+	if log := app.SetOption(types.OptionMaxBallotsPerTx, "2"); log == "" {
+		t.Fatal("expected an error setting a consensus option after genesis")
+	}
+
+	// but an admin can set them
+	adminTx := makeTestAdminTx(a1p, 1)
+	adminTx.Options = []types.Option{
+		{types.OptionMaxBallotsPerTx, "2"},
+		{types.OptionStrictBallots, "true"},
+	}
+	adminTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(adminTx)))
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// in strict mode, a bad ballot rejects the whole tx
+	tx = makeTestVoteTx(v1p, 2, 0, types.Candidate(nTestCandidates))
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// as does an empty ballot
+	tx = makeTestVoteTx(v1p, 2)
+	tx.Sign(v1s)
+	if r := app.AppendTx(types.JSONBytes(tx)); r.Code != types.CodeTypeBadBallot {
+		t.Fatalf("expected bad ballot. got code %v, log %s", r.Code, r.Log)
+	}
+	app.Commit()
+	expectTally(t, app, []int64{2, 1, 1, 1, 0})
+}
+
+//----------------------------------------------------------------------
+// test scheduled election
+
+func TestScheduledElection(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.SetOption(types.OptionOpenHeight, "3")
+	app.SetOption(types.OptionCloseHeight, "4")
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	var fired []string
+	for _, ev := range []string{types.EventElectionOpened, types.EventElectionClosed} {
+		ev := ev
+		app.EventSwitch().AddListenerForEvent("test", ev, func(data events.EventData) {
+			fired = append(fired, Fmt("%s %d", ev, data.(types.EventDataElection).Height))
+		})
+	}
+
+	// a vote in block h
+	for h, pass := range []bool{2: false, 3: true, 4: true, 5: false} {
+		if h < 2 {
+			continue
+		}
+		app.BeginBlock(nil, &tmsp.Header{Height: uint64(h)})
+		tx := makeTestVoteTx(v1p, h, 0)
+		tx.Sign(v1s)
+		if pass {
+			expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+		} else {
+			expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+		}
+		app.EndBlock(uint64(h))
+		app.Commit()
+	}
+
+	expectTally(t, app, []int64{2, 0, 0, 0, 0})
+	outcome, err := app.GetOutcome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome == nil || !outcome.HasWinner || outcome.Winner != 0 {
+		t.Fatalf("expected candidate 0 to win the scheduled election, got %v", outcome)
+	}
+	if len(fired) != 2 || fired[0] != "ElectionOpened 3" || fired[1] != "ElectionClosed 4" {
+		t.Fatalf("unexpected events %v", fired)
+	}
+}
+
+//----------------------------------------------------------------------
+// test a failed hook
+
+func TestFailedHook(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		ReplaceBallots: true,
+		CloseHeight:    3,
+		Candidates:     makeTestCandidates(nTestCandidates),
+	})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.Commit()
+
+	app.BeginBlock(nil, &tmsp.Header{Height: 2})
+	voteTx := makeTestVoteTx(v1p, 1, 0)
+	voteTx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(voteTx)))
+	delegateTx := makeTestDelegateTx(v2p, &v1p, 1)
+	delegateTx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(delegateTx)))
+	app.EndBlock(2)
+	app.Commit()
+
+	// the delegate's ballots can't be added to the tally,
+	// so the scheduled close fails without changing the state
+	app.BeginBlock(nil, &tmsp.Header{Height: 3})
+	app.blockState.SetBallots(v1p, []types.Ballot{{Choice: 99}})
+	app.EndBlock(3)
+	app.Commit()
+	if app.state.GetElection().Closed {
+		t.Fatal("expected the election to stay open")
+	}
+	// the delegated ballot isn't counted
+	expectTally(t, app, []int64{1, 0, 0, 0, 0})
+	if app.GetTally().Ballots != 1 {
+		t.Fatalf("expected 1 ballot in the tally, got %d", app.GetTally().Ballots)
+	}
+	if outcome, err := app.GetOutcome(); err != nil || outcome != nil {
+		t.Fatalf("expected no outcome, got %v %v", outcome, err)
+	}
+}
+
+//----------------------------------------------------------------------
+// test validator tx
+
+func TestValidatorTx(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	_, val1, _ := types.NewAccount(types.AccountTypeVoter)
+	_, val2, _ := types.NewAccount(types.AccountTypeVoter)
+	app.InitChain([]*tmsp.Validator{{PubKey: val1.Bytes(), Power: 10}})
+
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(a1p, a1a)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	// voters can't change the validators
+	tx := &types.ValidatorTx{
+		Validators: []types.Validator{{val2, 5}},
+		Nonce:      []byte{1},
+		PubKey:     v1p,
+	}
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// removing the only validator leaves no power
+	tx = &types.ValidatorTx{
+		Validators: []types.Validator{{val1, 0}},
+		Nonce:      []byte{1},
+		PubKey:     a1p,
+	}
+	tx.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// tendermint only takes ed25519 validators
+	_, secp, _ := types.NewAccountWithKey(types.AccountTypeVoter, crypto.GenPrivKeySecp256k1())
+	tx = &types.ValidatorTx{
+		Validators: []types.Validator{{secp, 5}},
+		Nonce:      []byte{1},
+		PubKey:     a1p,
+	}
+	tx.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// add one, and re-weight the other
+	tx = &types.ValidatorTx{
+		Validators: []types.Validator{{val2, 5}, {val1, 1}},
+		Nonce:      []byte{1},
+		PubKey:     a1p,
+	}
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	diffs := app.EndBlock(2)
+	if len(diffs) != 2 || !bytes.Equal(diffs[0].PubKey, val2.Bytes()) || diffs[0].Power != 5 || diffs[1].Power != 1 {
+		t.Fatalf("unexpected validator diffs %v", diffs)
+	}
+	app.Commit()
+	if diffs := app.EndBlock(3); len(diffs) != 0 {
+		t.Fatalf("expected no validator diffs in the next block, got %v", diffs)
+	}
+
+	vals, err := app.GetValidators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 2 {
+		t.Fatalf("expected 2 validators, got %v", vals)
+	}
+
+	// InitChain after genesis doesn't reset them
+	app.InitChain([]*tmsp.Validator{{PubKey: val1.Bytes(), Power: 10}})
+	app.Commit()
+	vals, err = app.GetValidators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 2 {
+		t.Fatalf("expected 2 validators after InitChain again, got %v", vals)
+	}
+
+	// or before the first block, once they're set
+	app = newLilVoterin(nTestCandidates)
+	app.InitChain([]*tmsp.Validator{{PubKey: val1.Bytes(), Power: 10}})
+	app.InitChain([]*tmsp.Validator{{PubKey: val2.Bytes(), Power: 5}})
+	app.Commit()
+	if vals, err = app.GetValidators(); err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 1 || vals[0].PubKey != val1 {
+		t.Fatalf("expected the first genesis validators, got %v", vals)
+	}
+}
+
+//----------------------------------------------------------------------
+// test multisig admin
+
+func TestMultisigAdmin(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	priv1, pub1, _ := types.NewAccount(types.AccountTypeAdmin)
+	priv2, pub2, _ := types.NewAccount(types.AccountTypeAdmin)
+	multisig := &types.MultisigKey{Threshold: 2, PubKeys: []types.PubKey{pub1, pub2}}
+	msPub := multisig.PubKey()
+	app.setAccount(msPub, &types.Account{Type: types.AccountTypeAdmin})
+	app.Commit()
+
+	_, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	tx := &types.AdminTx{
+		PubAccounts: []types.PubAccount{{v1p, v1a}},
+		Nonce:       []byte{1},
+		PubKey:      &msPub,
+		Multisig:    multisig,
+	}
+	tx.AddSignature(priv1)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	tx.AddSignature(priv2)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	if _, err := app.GetAccount(v1p); err != nil {
+		t.Fatal(err)
+	}
+
+	// the multisig can change the validators and close the election too
+	_, val1, _ := types.NewAccount(types.AccountTypeVoter)
+	valTx := &types.ValidatorTx{
+		Validators: []types.Validator{{val1, 5}},
+		Nonce:      []byte{2},
+		PubKey:     msPub,
+		Multisig:   multisig,
+	}
+	valTx.AddSignature(priv1)
+	expectFail(t, app.AppendTx(types.JSONBytes(valTx)))
+	valTx.AddSignature(priv2)
+	expectPass(t, app.AppendTx(types.JSONBytes(valTx)))
+
+	closeTx := &types.CloseTx{Nonce: []byte{3}, PubKey: msPub, Multisig: multisig}
+	closeTx.AddSignature(priv2)
+	expectFail(t, app.AppendTx(types.JSONBytes(closeTx)))
+	closeTx.AddSignature(priv1)
+	expectPass(t, app.AppendTx(types.JSONBytes(closeTx)))
+	app.Commit()
+
+	if outcome, err := app.GetOutcome(); err != nil || outcome == nil {
+		t.Fatalf("expected the election to be closed, got %v %v", outcome, err)
+	}
+}
+
+//----------------------------------------------------------------------
+// test proposals
+
+func TestProposals(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.SetOption(types.OptionProposalApprovals, "2")
+
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	a2s, a2p, a2a := types.NewAccount(types.AccountTypeAdmin)
+	a3s, a3p, a3a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(a1p, a1a)
+	app.setAccount(a2p, a2a)
+	app.setAccount(a3p, a3a)
+	app.Commit()
+
+	// propose a new voter in block 2, to be approved by block 3
+	_, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	propose := &types.ProposeAdminTx{
+		Payload: types.AdminPayload{PubAccounts: []types.PubAccount{{v1p, v1a}}},
+		Expires: 3,
+		Nonce:   []byte{1},
+		PubKey:  a1p,
+	}
+	propose.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(propose)))
+
+	// the proposer has already approved
+	approve := &types.ApproveTx{ProposalID: 1, Nonce: []byte{2}, PubKey: a1p}
+	approve.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(approve)))
+	app.Commit()
+
+	pending, err := app.GetPendingProposals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != 1 {
+		t.Fatalf("expected proposal 1 to be pending, got %v", pending)
+	}
+	if _, err := app.GetAccount(v1p); err == nil {
+		t.Fatal("expected the voter not to be added before approval")
+	}
+
+	// the second approval executes it
+	approve = &types.ApproveTx{ProposalID: 1, Nonce: []byte{1}, PubKey: a2p}
+	approve.Sign(a2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(approve)))
+	app.Commit()
+
+	if _, err := app.GetAccount(v1p); err != nil {
+		t.Fatal(err)
+	}
+	proposal, err := app.GetProposal(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Executed != 3 || len(proposal.Approvals) != 2 {
+		t.Fatalf("expected proposal executed at 3 with 2 approvals, got %v", proposal)
+	}
+
+	// it can't be approved again
+	approve = &types.ApproveTx{ProposalID: 1, Nonce: []byte{1}, PubKey: a3p}
+	approve.Sign(a3s)
+	expectFail(t, app.AppendTx(types.JSONBytes(approve)))
+
+	// a proposal can't be approved after it expires
+	propose.Expires = 4
+	propose.Nonce = []byte{3}
+	propose.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(propose)))
+	app.Commit()
+	app.Commit()
+	approve = &types.ApproveTx{ProposalID: 2, Nonce: []byte{2}, PubKey: a3p}
+	approve.Sign(a3s)
+	expectFail(t, app.AppendTx(types.JSONBytes(approve)))
+	if pending, _ := app.GetPendingProposals(); len(pending) != 0 {
+		t.Fatalf("expected no pending proposals, got %v", pending)
+	}
+}
+
+//----------------------------------------------------------------------
+// test interrupted commit
+
+func TestInterruptedCommit(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	tx := makeTestVoteTx(v1p, 1, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// crash after the commit of height 2 started
+	db.SetSync(sm.PendingKey, wire.BinaryBytes(2))
+
+	app = NewLilVoterin(db)
+	app.Load("")
+	if len(db.Get(sm.PendingKey)) != 0 {
+		t.Fatal("expected the pending commit to be cleared")
+	}
+	state, err := app.GetState(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.GetHeight() != 1 {
+		t.Fatalf("expected height 1 after the interrupted commit, got %d", state.GetHeight())
+	}
+	expectTally(t, app, []int64{0, 0, 0, 0, 0})
+
+	// the block can be replayed
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	expectTally(t, app, []int64{1, 0, 0, 0, 0})
+}
+
+//----------------------------------------------------------------------
+// test rolling back
+
+func TestRollback(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+	// the height before the latest is kept anyway
+	if err := app.SetPruning(sm.PruningPolicy{KeepRecent: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	hash1 := app.Commit().Data
+
+	tx := makeTestVoteTx(v1p, 1, 0)
+	tx.Sign(v1s)
+	app.BeginBlock(nil, &tmsp.Header{Height: 2, AppHash: hash1})
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.EndBlock(2)
+	hash2 := app.Commit().Data
+
+	// tendermint crashed before saving the block, so it executes it again after a restart
+	app = NewLilVoterin(db)
+	app.Load("")
+	app.BeginBlock(nil, &tmsp.Header{Height: 2, AppHash: hash1})
+	if height := app.state.GetHeight(); height != 1 {
+		t.Fatalf("expected a rollback to height 1, got %d", height)
+	}
+	expectTally(t, app, []int64{0, 0, 0, 0, 0})
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.EndBlock(2)
+	if res := app.Commit(); !bytes.Equal(res.Data, hash2) {
+		t.Fatalf("expected app hash %X executing the block again, got %X", hash2, res.Data)
+	}
+	expectFail(t, app.CheckTx(types.JSONBytes(tx)))
+
+	// the app can only be one block ahead
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected a panic for the app hash of an earlier height")
+			}
+		}()
+		app.BeginBlock(nil, &tmsp.Header{Height: 3, AppHash: hash2})
+		app.EndBlock(3)
+		app.Commit()
+		app.BeginBlock(nil, &tmsp.Header{Height: 3, AppHash: hash1})
+	}()
+
+	// the block after the close compacts the voters' nonces,
+	// and compacts them again when it's executed again
+	app = NewLilVoterin(db)
+	app.Load("")
+	closeTx := &types.CloseTx{Nonce: []byte{1}, PubKey: a1p}
+	closeTx.Sign(a1s)
+	app.BeginBlock(nil, &tmsp.Header{Height: 4})
+	expectPass(t, app.AppendTx(types.JSONBytes(closeTx)))
+	app.EndBlock(4)
+	hashClosed := app.Commit().Data
+	app.BeginBlock(nil, &tmsp.Header{Height: 5, AppHash: hashClosed})
+	app.EndBlock(5)
+	hash5 := app.Commit().Data
+	app.BeginBlock(nil, &tmsp.Header{Height: 5, AppHash: hashClosed})
+	if len(db.Get([]byte(sm.NonceKey(v1p, tx.Nonce)))) != 0 {
+		t.Fatal("expected the voter's nonces to stay compacted")
+	}
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	app.EndBlock(5)
+	if res := app.Commit(); !bytes.Equal(res.Data, hash5) {
+		t.Fatalf("expected app hash %X executing the block again, got %X", hash5, res.Data)
+	}
+	if _, err := app.state.Export(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//----------------------------------------------------------------------
+// test loading the root
+
+func TestLoadChecksRoot(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+	_, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+	_, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v2p, v2a)
+	app.Commit()
+
+	// a block from a chain with another app hash is refused
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected a panic for a header with another app hash")
+			}
+		}()
+		app.BeginBlock(nil, &tmsp.Header{Height: 3, AppHash: []byte("another chain's app hash")})
+	}()
+
+	// the state's root must be the one committed at its height
+	root := db.Get(sm.StateKey)
+	db.Set(sm.StateKey, db.Get(sm.RootKey(1)))
+	if err := sm.NewState(db).Load(); err == nil {
+		t.Fatal("expected an error loading a root that isn't the height's")
+	}
+	db.Set(sm.StateKey, root)
+	if err := sm.NewState(db).Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//----------------------------------------------------------------------
+// test pruning
+
+func TestPruning(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+	if err := app.SetPruning(sm.PruningPolicy{KeepRecent: 2, KeepEvery: 3}); err != nil {
+		t.Fatal(err)
+	}
+	// each height has a new account, so each has its own root
+	roots := make(map[int][]byte)
+	for i := 0; i < 8; i++ {
+		_, pub, acc := types.NewAccount(types.AccountTypeVoter)
+		app.setAccount(pub, acc)
+		app.Commit()
+		roots[app.state.GetHeight()] = app.state.GetAppHash()
+	}
+
+	// the last 2 heights and every 3rd are kept
+	for height, kept := range map[int]bool{
+		1: false, 2: false, 3: true, 4: false, 5: false, 6: true, 7: true,
+	} {
+		// loading a kept height reads every node of its tree
+		_, err := app.GetState(height)
+		if kept && err != nil {
+			t.Fatalf("expected height %d to be kept: %v", height, err)
+		} else if !kept && err == nil {
+			t.Fatalf("expected height %d to be pruned", height)
+		}
+
+		// the nodes of a pruned height are deleted
+		if root := db.Get(roots[height]); kept != (len(root) > 0) {
+			t.Fatalf("expected the root node of height %d to be kept %v", height, kept)
+		}
+	}
+}
+
+//----------------------------------------------------------------------
+// test compacting nonces
+
+func TestCompactNonces(t *testing.T) {
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	app := NewLilVoterin(db)
+	app.setElection(&types.Election{Candidates: makeTestCandidates(nTestCandidates)})
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	voteTx := makeTestVoteTx(v1p, 1, 0)
+	voteTx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(voteTx)))
+	adminTx := makeTestAdminTx(a1p, 1)
+	adminTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(adminTx)))
+	app.Commit()
+
+	if len(db.Get([]byte(sm.NonceKey(v1p, voteTx.Nonce)))) == 0 {
+		t.Fatal("expected the voter's nonce to be saved")
+	}
+
+	// each block's nonces are added to the voter's list
+	voteTx2 := makeTestVoteTx(v1p, 2, 0)
+	voteTx2.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(voteTx2)))
+	app.Commit()
+	for i := 0; i < 2; i++ {
+		if len(db.Get(sm.NonceListKey(v1p, i))) == 0 {
+			t.Fatalf("expected a nonce listed at %d", i)
+		}
+	}
+
+	// the nonces expire at the end of the block after the election closes
+	app.BeginBlock(nil, &tmsp.Header{Height: 4})
+	closeTx := &types.CloseTx{Nonce: []byte{2}, PubKey: a1p}
+	closeTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(closeTx)))
+	app.EndBlock(4)
+	app.Commit()
+	if len(db.Get([]byte(sm.NonceKey(v1p, voteTx.Nonce)))) == 0 {
+		t.Fatal("expected the voter's nonces to be kept in the block the election closes in")
+	}
+	app.BeginBlock(nil, &tmsp.Header{Height: 5})
+	app.EndBlock(5)
+	app.Commit()
+
+	// the voter can't vote again, so their nonces are gone,
+	// but the admin's are kept
+	if len(db.Get([]byte(sm.NonceKey(v1p, voteTx.Nonce)))) != 0 || len(db.Get(sm.NonceListKey(v1p, 1))) != 0 {
+		t.Fatal("expected the voter's nonces to be compacted")
+	}
+	if len(db.Get([]byte(sm.NonceKey(a1p, adminTx.Nonce)))) == 0 {
+		t.Fatal("expected the admin's nonce to be kept")
+	}
+	expectFail(t, app.CheckTx(types.JSONBytes(voteTx)))
+	expectFail(t, app.CheckTx(types.JSONBytes(adminTx)))
+
+	// heights from before the compaction can't be exported
+	state, err := app.GetState(app.state.GetHeight() - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Export(); err == nil {
+		t.Fatal("expected an error exporting a height before the compaction")
+	}
+}
+
+//----------------------------------------------------------------------
+// test snapshots
+
+func TestSnapshot(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.setAccount(a1p, a1a)
+	app.Commit()
+
+	tx := makeTestWriteInTx(v1p, 1, "Dave")
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// v2 votes and is deleted, but its nonce is still in the snapshot
+	v2Tx := makeTestVoteTx(v2p, 1, 0)
+	v2Tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(v2Tx)))
+	var delTx types.Tx = types.MakeAccountActionTx(a1p, types.AccountAction{PubKey: v2p, Action: types.AccountActionDelete}, []byte{0})
+	delTx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(delTx)))
+	app.Commit()
+
+	state, err := app.GetState(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := state.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nonces used after the height aren't in its snapshot
+	tx2 := makeTestWriteInTx(v1p, 2, "Eve")
+	tx2.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx2)))
+	app.Commit()
+	state, err = app.GetState(snap.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapBefore, err := state.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wire.JSONBytes(snapBefore), wire.JSONBytes(snap)) {
+		t.Fatal("expected the snapshot of a height not to change after it")
+	}
+
+	// round trip through the JSON
+	snap2 := new(sm.Snapshot)
+	wire.ReadJSONPtr(snap2, wire.JSONBytes(snap), &err)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := dbm.NewDB("lil-voterin-app", "memdb", "")
+	if _, err := sm.ImportSnapshot(db, snap2); err != nil {
+		t.Fatal(err)
+	}
+	app2 := NewLilVoterin(db)
+	app2.Load("")
+	expectTally(t, app2, snap.Tally.Counts)
+	if h := app2.state.GetHeight(); h != snap.Height {
+		t.Fatalf("expected the imported state at height %d, got %d", snap.Height, h)
+	}
+	if res := app2.Commit(); !bytes.Equal(res.Data, snap.AppHash) {
+		t.Fatalf("app hash %X does not match the snapshot's %X", res.Data, snap.AppHash)
+	}
+
+	// the nonce can't be replayed, but the one used after the height can be
+	expectFail(t, app2.CheckTx(types.JSONBytes(tx)))
+	expectPass(t, app2.CheckTx(types.JSONBytes(tx2)))
+
+	// nor can the deleted account's, if it's added again
+	addTx := types.MakeAdminTx(a1p, v2p, types.AccountTypeVoter, []byte{1})
+	addTx.Sign(a1s)
+	expectPass(t, app2.AppendTx(types.JSONBytes(addTx)))
+	app2.Commit()
+	if r := app2.CheckTx(types.JSONBytes(v2Tx)); r.Code != tmsp.CodeType_BadNonce {
+		t.Fatalf("expected the deleted account's nonce to be used. got code %v, log %s", r.Code, r.Log)
+	}
+
+	// a bad snapshot isn't imported
+	snap2.Tally.Counts[0] += 1
+	if _, err := sm.ImportSnapshot(dbm.NewDB("lil-voterin-app", "memdb", ""), snap2); err == nil {
+		t.Fatal("expected an error for a snapshot that doesn't match its app hash")
+	}
+}
+
+//----------------------------------------------------------------------
+// test throughput
+
+func TestThroughput(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	priv1, pub1, acc1 := types.NewAccount(types.AccountTypeVoter)
+	priv2, pub2, acc2 := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(pub1, acc1)
+	app.setAccount(pub2, acc2)
+	app.Commit()
+
+	ntxs := 1000
+	nballots := 10
+
+	tally := types.NewTally(nTestCandidates)
+	start := time.Now()
+	for i := 0; i < ntxs; i++ {
+		var tx *types.VoteTx
+		// split txs over two accounts
+		if i%2 == 0 {
+			tx = types.MakeVoteTx(tally, pub1, i, nTestCandidates, nballots)
+			tx.Sign(priv1)
+		} else {
+			tx = types.MakeVoteTx(tally, pub2, i, nTestCandidates, nballots)
+			tx.Sign(priv2)
+		}
+		r := app.AppendTx(types.JSONBytes(tx))
+		if r.Code != 0 {
+			t.Fatalf("Transaction failed %v: %v", r, string(types.JSONBytes(tx)))
+		}
+	}
+
+	fmt.Printf("took %v. %v ballots/sec\n", time.Since(start), float64(ntxs*nballots)/float64(time.Since(start).Seconds()))
+
+	app.Commit()
+
+	appTally := app.GetTally()
+	for i, c := range tally.Counts {
+		if c != appTally.Counts[i] {
+			t.Fatalf("tallys don't match for index %d. got %d, expected %d", i, appTally.Counts[i], c)
+		}
+	}
+}
+
+//----------------------------------------------------------------------
+// test account lifecycle
+
+func TestAccountLifecycle(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(a1p, a1a)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.Commit()
+
+	var tx types.Tx
+	var r tmsp.Result
+	nonce := 0
+
+	accountAction := func(signer types.PubKey, priv crypto.PrivKey, action types.AccountAction) tmsp.Result {
+		tx := types.MakeAccountActionTx(signer, action, []byte{byte(nonce)})
+		tx.Sign(priv)
+		nonce += 1
+		return app.AppendTx(types.JSONBytes(tx))
+	}
+	vote := func(pub types.PubKey, priv crypto.PrivKey) tmsp.Result {
+		tx = makeTestTx(pub, nonce)
+		tx.Sign(priv)
+		nonce += 1
+		return app.AppendTx(types.JSONBytes(tx))
+	}
+	countAccounts := func(status types.AccountStatus) int {
+		accs, err := app.GetAccounts(status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(accs)
+	}
+
+	// a suspension needs a reason, and voters can't suspend anyone
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v1p, Action: types.AccountActionSuspend})
+	expectFail(t, r)
+	r = accountAction(v2p, v2s, types.AccountAction{PubKey: v1p, Action: types.AccountActionSuspend, Reason: "test"})
+	expectFail(t, r)
+
+	// suspend voter1 until reinstated
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v1p, Action: types.AccountActionSuspend, Reason: "lost key"})
+	expectPass(t, r)
+	r = vote(v1p, v1s)
+	expectFail(t, r)
+
+	// setting the account again keeps the suspension,
+	// and a suspension can't be set without an account action
+	setAccount := func(acc *types.Account) tmsp.Result {
+		tx := types.MakeAdminTx(a1p, v1p, types.AccountTypeVoter, []byte{byte(nonce)})
+		tx.PubAccounts[0].Account = acc
+		tx.Sign(a1s)
+		nonce += 1
+		return app.AppendTx(types.JSONBytes(tx))
+	}
+	r = setAccount(&types.Account{Type: types.AccountTypeVoter})
+	expectPass(t, r)
+	r = vote(v1p, v1s)
+	expectFail(t, r)
+	r = setAccount(&types.Account{Type: types.AccountTypeVoter, Suspension: &types.Suspension{Reason: "sneaky"}})
+	expectFail(t, r)
+
+	// suspend voter2 for this block only
+	height := app.state.GetBlockHeight()
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v2p, Action: types.AccountActionSuspend, Reason: "audit", Until: height})
+	expectPass(t, r)
+	r = vote(v2p, v2s)
+	expectFail(t, r)
+	app.Commit()
+
+	// voter2's suspension has expired
+	if n := countAccounts(types.AccountStatusSuspended); n != 1 {
+		t.Fatalf("Expected 1 suspended account, got %d", n)
+	}
+	if n := countAccounts(types.AccountStatusActive); n != 2 {
+		t.Fatalf("Expected 2 active accounts, got %d", n)
+	}
+	r = vote(v2p, v2s)
+	expectPass(t, r)
+
+	// only suspended accounts can be reinstated
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v2p, Action: types.AccountActionReinstate})
+	expectFail(t, r)
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v1p, Action: types.AccountActionReinstate})
+	expectPass(t, r)
+	r = vote(v1p, v1s)
+	expectPass(t, r)
+
+	// delete voter1
+	r = accountAction(a1p, a1s, types.AccountAction{PubKey: v1p, Action: types.AccountActionDelete})
+	expectPass(t, r)
+	r = vote(v1p, v1s)
+	expectFail(t, r)
+	app.Commit()
+
+	if n := countAccounts(""); n != 2 {
+		t.Fatalf("Expected 2 accounts, got %d", n)
+	}
+	if _, err := app.state.GetAccount(v1p); err == nil {
+		t.Fatal("Expected deleted account to be removed")
+	}
+
+	// the history is kept after the account is deleted
+	history, err := app.GetAccountHistory(v1p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := []string{types.AccountActionSuspend, types.AccountActionReinstate, types.AccountActionDelete}
+	if len(history) != len(actions) {
+		t.Fatalf("Expected %d events in the history, got %d", len(actions), len(history))
+	}
+	for i, event := range history {
+		if event.Action != actions[i] || event.By != a1p {
+			t.Fatalf("Expected %s by %X, got %s by %X", actions[i], a1p, event.Action, event.By)
+		}
+	}
+	if history[0].Reason != "lost key" {
+		t.Fatalf("Expected the suspension reason to be recorded, got %q", history[0].Reason)
+	}
+}
+
+//----------------------------------------------------------------------
+// test key rotation
+
+func TestRotateKey(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		ReplaceBallots: true,
+		Candidates:     makeTestCandidates(nTestCandidates),
+	})
+
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	_, v3p, v3a := types.NewAccount(types.AccountTypeVoter)
+	v1a.BallotQuota = 2
+	app.setAccount(a1p, a1a)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.setAccount(v3p, v3a)
+	app.Commit()
+
+	n1s, n1p, _ := types.NewAccount(types.AccountTypeVoter)
+	n3s, n3p, _ := types.NewAccount(types.AccountTypeVoter)
+
+	var tx types.Tx
+	var r tmsp.Result
+
+	// voter1 uses its quota and voter2 delegates to it
+	tx = makeTestTx(v1p, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestDelegateTx(v2p, &v1p, 0)
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// the new key must sign a self-signed rotation
+	rotate := &types.RotateKeyTx{OldPubKey: v1p, NewPubKey: n1p, Nonce: []byte{1}, PubKey: v1p}
+	rotate.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(rotate)))
+
+	rotate.SignNew(n1s)
+	rotate.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(rotate)))
+
+	// the old key is gone, and the new key has the old one's ballots and delegators
+	tx = makeTestTx(v1p, 2)
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestTx(n1p, 0)
+	tx.Sign(n1s)
+	r = app.AppendTx(types.JSONBytes(tx))
+	if r.Code != types.CodeTypeBallotQuotaExceeded {
+		t.Fatalf("Expected the ballot count to move with the account, got code %v, log %s", r.Code, r.Log)
+	}
+	app.Commit()
+
+	if delegate, _ := app.state.GetDelegate(v2p); delegate == nil || *delegate != n1p {
+		t.Fatalf("Expected voter2 to delegate to the new key, got %v", delegate)
+	}
+	if !app.state.HasVoted(n1p) || app.state.HasVoted(v1p) {
+		t.Fatal("Expected the ballots to move to the new key")
+	}
+
+	acc, err := app.state.GetAccount(n1p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Sequence != 2 || acc.BallotQuota != 2 {
+		t.Fatalf("Expected the account to move with its sequence and quota, got %v", acc)
+	}
+
+	// an admin replaces voter3's lost key
+	rotate = &types.RotateKeyTx{OldPubKey: v3p, NewPubKey: n3p, Nonce: []byte{0}, PubKey: a1p}
+	rotate.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(rotate)))
+	tx = makeTestTx(n3p, 0)
+	tx.Sign(n3s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// voters can't rotate each other's keys
+	_, n2p, _ := types.NewAccount(types.AccountTypeVoter)
+	rotate = &types.RotateKeyTx{OldPubKey: n1p, NewPubKey: n2p, Nonce: []byte{1}, PubKey: v2p}
+	rotate.Sign(v2s)
+	expectFail(t, app.AppendTx(types.JSONBytes(rotate)))
+
+	// used keys can't be rotated to, and rotated keys can't be added again
+	rotate = &types.RotateKeyTx{OldPubKey: v2p, NewPubKey: v1p, Nonce: []byte{2}, PubKey: a1p}
+	rotate.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(rotate)))
+	tx = types.MakeAdminTx(a1p, v3p, types.AccountTypeVoter, []byte{3})
+	tx.Sign(a1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	app.Commit()
+
+	// both keys record the rotation
+	history, err := app.state.GetAccountHistory(v3p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Action != types.AccountActionRotate || *history[0].Key != n3p || history[0].By != a1p {
+		t.Fatalf("Bad history for the old key: %v", history)
+	}
+	history, err = app.state.GetAccountHistory(n3p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || *history[0].Key != v3p {
+		t.Fatalf("Bad history for the new key: %v", history)
+	}
+}
+
+//----------------------------------------------------------------------
+// test eligibility
+
+func TestEligibility(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+	app.setElection(&types.Election{
+		ReplaceBallots: true,
+		Candidates:     makeTestCandidates(nTestCandidates),
+	})
+
+	o1s, o1p, o1a := types.NewAccount(types.AccountTypeOfficer)
+	r1s, r1p, r1a := types.NewAccount(types.AccountTypeRegistrar)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	v3s, v3p, v3a := types.NewAccount(types.AccountTypeVoter)
+	v3a.Tags = []string{"district-4"}
+	app.setAccount(o1p, o1a)
+	app.setAccount(r1p, r1a)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.setAccount(v3p, v3a)
+	app.Commit()
+
+	var tx types.Tx
+	nonce := 0
+	eligibility := func(pub types.PubKey, priv crypto.PrivKey, action types.EligibilityAction) tmsp.Result {
+		tx := &types.AdminTx{
+			Eligibility: []types.EligibilityAction{action},
+			Nonce:       []byte{byte(nonce)},
+			PubKey:      &pub,
+		}
+		tx.Sign(priv)
+		nonce += 1
+		return app.AppendTx(types.JSONBytes(tx))
+	}
+
+	// every voter is eligible until the election is restricted
+	tx = makeTestTx(v2p, 0)
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// restricting the election needs permission to manage it
+	r := eligibility(r1p, r1s, types.EligibilityAction{Voters: []types.PubKey{v1p}})
+	expectFail(t, r)
+	r = eligibility(o1p, o1s, types.EligibilityAction{Voters: []types.PubKey{v1p}, Tags: []string{"district-4"}})
+	expectPass(t, r)
+	r = eligibility(o1p, o1s, types.EligibilityAction{Voters: []types.PubKey{v1p}})
+	expectFail(t, r) // already on the list
+
+	// voter1 is on the list and voter3 has the tag
+	tx = makeTestTx(v1p, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestTx(v3p, 0)
+	tx.Sign(v3s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestTx(v2p, 1)
+	tx.Sign(v2s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// voter2 can't delegate or be delegated to
+	tx = makeTestDelegateTx(v2p, &v1p, 2)
+	tx.Sign(v2s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestDelegateTx(v1p, &v2p, 1)
+	tx.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// a registrar can tag voter2 into the district
+	tx = &types.AdminTx{
+		PubAccounts: []types.PubAccount{{v2p, &types.Account{Type: types.AccountTypeVoter, Tags: []string{"district-4"}}}},
+		Nonce:       []byte{0},
+		PubKey:      &r1p,
+	}
+	tx.Sign(r1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestTx(v2p, 3)
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// removing the tag makes voter3 ineligible
+	r = eligibility(o1p, o1s, types.EligibilityAction{Remove: true, Tags: []string{"district-4"}})
+	expectPass(t, r)
+	tx = makeTestTx(v3p, 1)
+	tx.Sign(v3s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	// the committed election, accounts and eligibility list show who was eligible
+	state := app.state
+	election := state.GetElection()
+	if !election.IsRestricted() || election.EligibleVoters != 1 || election.EligibleTags != 0 || !state.HasEligibleVoter(v1p) {
+		t.Fatalf("Bad eligibility in the election: %v", election)
+	}
+	for _, c := range []struct {
+		pub      types.PubKey
+		eligible bool
+	}{{v1p, true}, {v2p, false}, {v3p, false}} {
+		acc, err := state.GetAccount(c.pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.IsEligible(c.pub, acc) != c.eligible {
+			t.Fatalf("Expected eligibility of %X to be %v", c.pub, c.eligible)
+		}
+		// the voter's key on the list is proven, or the keys either side of it
+		proof, err := state.KeyProof(types.EligibleKeyBytes(c.pub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.Exists != c.eligible {
+			t.Fatalf("Expected %X on the eligibility list to be %v", c.pub, c.eligible)
+		}
+		if !proof.Exists && (proof.Left == nil && proof.Right == nil) {
+			t.Fatalf("Expected proofs of the keys next to %X", c.pub)
+		}
+	}
+	if _, err := state.Proof(types.ElectionKeyBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	// rotating voter1's key moves it on the list
+	n1s, n1p, _ := types.NewAccount(types.AccountTypeVoter)
+	rotate := &types.RotateKeyTx{OldPubKey: v1p, NewPubKey: n1p, Nonce: []byte{100}, PubKey: v1p}
+	rotate.SignNew(n1s)
+	rotate.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(rotate)))
+	app.Commit()
+	if app.state.HasEligibleVoter(v1p) || !app.state.HasEligibleVoter(n1p) || app.state.GetElection().EligibleVoters != 1 {
+		t.Fatal("Expected the new key to replace the old on the eligibility list")
+	}
+
+	// removing the last voter leaves the election restricted, with nobody eligible
+	r = eligibility(o1p, o1s, types.EligibilityAction{Remove: true, Voters: []types.PubKey{n1p}})
+	expectPass(t, r)
+	tx = makeTestTx(n1p, 1)
+	tx.Sign(n1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	tx = makeTestTx(v2p, 4)
+	tx.Sign(v2s)
+	expectFail(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	if election := app.state.GetElection(); !election.IsRestricted() || election.EligibleVoters != 0 || election.EligibleTags != 0 {
+		t.Fatalf("Expected a restricted election with an empty list, got %v", election)
+	}
+
+	// until the option lifts the restriction
+	optionTx := &types.AdminTx{
+		Options: []types.Option{{types.OptionRestricted, "false"}},
+		Nonce:   []byte{byte(nonce)},
+		PubKey:  &o1p,
+	}
+	optionTx.Sign(o1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(optionTx)))
+	tx = makeTestTx(v2p, 5)
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+}
+
+//----------------------------------------------------------------------
+// test account index
+
+func TestAccountIndex(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	a1s, a1p, a1a := types.NewAccount(types.AccountTypeAdmin)
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(a1p, a1a)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	// accounts are numbered in the order they're created
+	_, v2p, _ := types.NewAccount(types.AccountTypeVoter)
+	tx := types.MakeAdminTx(a1p, v2p, types.AccountTypeVoter, []byte{0})
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	for i, pub := range []types.PubKey{a1p, v1p, v2p} {
+		acc, err := app.state.GetAccount(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Index != i+1 {
+			t.Fatalf("Expected account %X to have index %d, got %d", pub, i+1, acc.Index)
+		}
+		if p, err := app.state.GetPubKeyByIndex(i + 1); err != nil || p != pub {
+			t.Fatalf("Expected index %d to be %X, got %X (%v)", i+1, pub, p, err)
+		}
+	}
+
+	// the signature is over the pubkey, so the tx can name the signer either way
+	indexedVoteTx := func(pub types.PubKey, priv crypto.PrivKey, index, nonce int) *types.VoteTx {
+		tx := makeTestTx(pub, nonce)
+		tx.Sign(priv)
+		tx.PubKey = nil
+		tx.Index = index
+		return tx
+	}
+	expectPass(t, app.AppendTx(types.JSONBytes(indexedVoteTx(v1p, v1s, 2, 0))))
+
+	// without the pubkey, the tx is shorter
+	fullTx := makeTestTx(v1p, 0)
+	fullTx.Sign(v1s)
+	if full, indexed := len(types.JSONBytes(fullTx)), len(types.JSONBytes(indexedVoteTx(v1p, v1s, 2, 0))); indexed >= full {
+		t.Fatalf("Expected the indexed tx (%d bytes) to be shorter than with the pubkey (%d bytes)", indexed, full)
+	}
+
+	// the tx must name the signer one way or the other
+	expectFail(t, app.AppendTx(types.JSONBytes(indexedVoteTx(v1p, v1s, 0, 1))))
+
+	// the index must be an account, and match the pubkey if both are set
+	expectFail(t, app.AppendTx(types.JSONBytes(indexedVoteTx(v1p, v1s, 4, 1))))
+	expectFail(t, app.AppendTx(types.JSONBytes(indexedVoteTx(v1p, v1s, 1, 1))))
+	vote := makeTestTx(v1p, 1)
+	vote.Index = 1
+	vote.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(vote)))
+	vote.Index = 2
+	expectPass(t, app.AppendTx(types.JSONBytes(vote)))
+
+	// updates keep the index
+	tx = types.MakeAdminTx(a1p, v1p, types.AccountTypeVoter, []byte{1})
+	tx.PubAccounts[0].Account.Index = 7
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// a rotated account keeps its index, which now names the new key
+	n1s, n1p, _ := types.NewAccount(types.AccountTypeVoter)
+	rotate := &types.RotateKeyTx{OldPubKey: v1p, NewPubKey: n1p, Nonce: []byte{2}, PubKey: v1p}
+	rotate.SignNew(n1s)
+	rotate.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(rotate)))
+	app.Commit()
+
+	acc, err := app.state.GetAccount(n1p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Index != 2 {
+		t.Fatalf("Expected the rotated account to keep index 2, got %d", acc.Index)
+	}
+	expectPass(t, app.AppendTx(types.JSONBytes(indexedVoteTx(n1p, n1s, 2, 0))))
+	expectFail(t, app.AppendTx(types.JSONBytes(indexedVoteTx(v1p, v1s, 2, 3))))
+}
+
+//----------------------------------------------------------------------
+// test secp256k1 keys
+
+func TestSecp256k1(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	a1s, a1p, a1a := types.NewAccountWithKey(types.AccountTypeAdmin, crypto.GenPrivKeySecp256k1())
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(a1p, a1a)
+	app.setAccount(v1p, v1a)
+	app.Commit()
+
+	// a secp256k1 admin adds a secp256k1 voter, who can vote
+	v2s, v2p, _ := types.NewAccountWithKey(types.AccountTypeVoter, crypto.GenPrivKeySecp256k1())
+	tx := types.MakeAdminTx(a1p, v2p, types.AccountTypeVoter, []byte{0})
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	vote := makeTestTx(v2p, 0)
+	vote.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(vote)))
+
+	// a signature by another key type doesn't verify
+	vote = makeTestTx(v2p, 1)
+	vote.Sign(v1s)
+	expectFail(t, app.AppendTx(types.JSONBytes(vote)))
+
+	// keys of both types can share a multisig
+	multisig := &types.MultisigKey{Threshold: 2, PubKeys: []types.PubKey{a1p, v1p}}
+	tx = types.MakeAdminTx(a1p, multisig.PubKey(), types.AccountTypeAdmin, []byte{1})
+	tx.Sign(a1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	_, v3p, _ := types.NewAccount(types.AccountTypeVoter)
+	tx = types.MakeAdminTx(multisig.PubKey(), v3p, types.AccountTypeVoter, []byte{0})
+	tx.Multisig = multisig
+	tx.AddSignature(a1s)
+	tx.AddSignature(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+
+	// accounts of every key type are listed
+	accs, err := app.GetAccounts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accs) != 5 {
+		t.Fatalf("Expected 5 accounts, got %d", len(accs))
+	}
+	for _, pub := range []types.PubKey{a1p, v1p, v2p, v3p, multisig.PubKey()} {
+		if _, err := app.state.GetAccount(pub); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//----------------------------------------------------------------------
+// test signature cache
+
+func TestSigCache(t *testing.T) {
+	app := newLilVoterin(nTestCandidates)
+
+	v1s, v1p, v1a := types.NewAccount(types.AccountTypeVoter)
+	v2s, v2p, v2a := types.NewAccount(types.AccountTypeVoter)
+	_, v3p, v3a := types.NewAccount(types.AccountTypeVoter)
+	app.setAccount(v1p, v1a)
+	app.setAccount(v2p, v2a)
+	app.setAccount(v3p, v3a)
+	app.Commit()
+
+	// a tx checked in CheckTx passes AppendTx from the cache
+	tx := makeTestVoteTx(v1p, 0, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.CheckTx(types.JSONBytes(tx)))
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+
+	// a bad signature fails in CheckTx and AppendTx alike,
+	// and the txs after it are unaffected
+	bad := makeTestVoteTx(v3p, 0, 2)
+	bad.Sign(v1s)
+	expectFail(t, app.CheckTx(types.JSONBytes(bad)))
+	expectFail(t, app.AppendTx(types.JSONBytes(bad)))
+	tx = makeTestVoteTx(v2p, 0, 1)
+	tx.Sign(v2s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+	app.Commit()
+	expectTally(t, app, []int64{1, 1, 0, 0, 0})
+
+	// with the cache off, each signature is checked on its own
+	app.SetSigCache(0)
+	expectFail(t, app.AppendTx(types.JSONBytes(bad)))
+	tx = makeTestVoteTx(v1p, 1, 0)
+	tx.Sign(v1s)
+	expectPass(t, app.AppendTx(types.JSONBytes(tx)))
+}
+
+// AppendTx a block of 100 txs. If checked, the txs are first given to CheckTx,
+// untimed, as tendermint does before they're in a block, so AppendTx finds their signatures cached
+func benchmarkAppendTx(b *testing.B, checked bool) {
+	app := newLilVoterin(nTestCandidates)
+	privs := make([]crypto.PrivKey, 100)
+	pubs := make([]types.PubKey, len(privs))
+	for i := range privs {
+		var acc *types.Account
+		privs[i], pubs[i], acc = types.NewAccount(types.AccountTypeVoter)
+		app.setAccount(pubs[i], acc)
+	}
+	app.Commit()
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		txs := make([][]byte, len(privs))
+		for i := range privs {
+			tx := makeTestVoteTx(pubs[i], n, 0)
+			tx.Sign(privs[i])
+			txs[i] = types.JSONBytes(tx)
+			if checked {
+				expectPass(b, app.CheckTx(txs[i]))
+			}
+		}
+		b.StartTimer()
+
+		h := app.state.GetHeight() + 1
+		app.BeginBlock(nil, &tmsp.Header{Height: uint64(h)})
+		for _, txBytes := range txs {
+			expectPass(b, app.AppendTx(txBytes))
+		}
+		app.EndBlock(uint64(h))
+		app.Commit()
+	}
+}
+
+func BenchmarkAppendTx(b *testing.B) {
+	benchmarkAppendTx(b, false)
+}
+
+func BenchmarkAppendTxChecked(b *testing.B) {
+	benchmarkAppendTx(b, true)
 }
@@ -0,0 +1,7 @@
+package app
+
+import (
+	"github.com/tendermint/go-logger"
+)
+
+var log = logger.New("module", "app")
@@ -10,9 +10,14 @@ import (
 var (
 	appGenesisFile string
 	appDataDir     string
-	nCandidates    int
 	tmspServer     string
 	tmspAddr       string
+	appKeepRecent  int
+	appKeepEvery   int
+	appSigCache    int
+	exportHeight   int
+	snapshotFile   string
+	genesisChainID string
 )
 
 func parseFlags(config cfg.Config, args []string) {
@@ -38,9 +43,14 @@ func parseFlags(config cfg.Config, args []string) {
 
 	flags.StringVar(&appGenesisFile, "app_genesis", "genesis.json", "App genesis file")
 	flags.StringVar(&appDataDir, "app_data", "lil_voterin_data", "App data directory")
+	flags.IntVar(&appKeepRecent, "app_keep_recent", 0, "Number of recent heights of app state to keep, at least 2. Older heights are deleted from disk. 0 keeps all")
+	flags.IntVar(&appKeepEvery, "app_keep_every", 0, "Also keep app state queryable every this many heights. 0 disables")
+	flags.IntVar(&appSigCache, "app_sig_cache", 10000, "Number of signatures checked in CheckTx to remember, so AppendTx doesn't check them again. 0 checks every signature")
+	flags.IntVar(&exportHeight, "height", 0, "Height of the app state to export. 0 is the latest")
+	flags.StringVar(&snapshotFile, "snapshot", "", "Snapshot file to export to or import from. Export writes to stdout if empty")
+	flags.StringVar(&genesisChainID, "chain_id", "", "Chain ID of the genesis written by import")
 	flags.StringVar(&tmspServer, "tmsp", "", "'socket' or 'grpc'. Leave empty to run in-proc with tendermint")
 	flags.StringVar(&tmspAddr, "tmsp-addr", "tcp://127.0.0.1:46658", "Address of tmsp endpoint")
-	flags.IntVar(&nCandidates, "nCandidates", 19, "Number of candidates per ballot")
 
 	flags.Parse(args)
 	if printHelp {
@@ -24,6 +24,7 @@ import (
 
 	"github.com/tendermint/lil-voterin/app"
 	rpc "github.com/tendermint/lil-voterin/rpc"
+	sm "github.com/tendermint/lil-voterin/state"
 )
 
 var config cfg.Config
@@ -49,6 +50,8 @@ func main() {
 
 Commands:
     node            Run the tendermint node
+    export          Export a snapshot of the app state
+    import          Build the app genesis state and the chain's genesis from a snapshot
     version         Show version info
 `)
 		return
@@ -68,6 +71,10 @@ Commands:
 		} else {
 			RunLilVoterinTendermint(config)
 		}
+	case "export":
+		ExportSnapshot()
+	case "import":
+		ImportSnapshot()
 	case "version":
 		fmt.Println("Tendermint", version.Version)
 		fmt.Println("LilVoterin", "0.1.0")
@@ -80,14 +87,25 @@ func LoadLilVoterin(config cfg.Config) *app.LilVoterin {
 	// create db for app
 	db := dbm.NewDB("lil-voterin-app", "leveldb", appDataDir)
 
-	voterApp := app.NewLilVoterin(db, nCandidates)
+	voterApp := app.NewLilVoterin(db)
+	err := voterApp.SetPruning(sm.PruningPolicy{
+		KeepRecent: appKeepRecent,
+		KeepEvery:  appKeepEvery,
+	})
+	if err != nil {
+		Exit("pruning: " + err.Error())
+	}
+	voterApp.SetSigCache(appSigCache)
 	voterApp.Load(appGenesisFile)
 
 	// set the app rpc
 	rpc.SetLilVoterin(voterApp)
 	mux := http.NewServeMux()
+	// subscribe to the election events over the websocket
+	wm := rpcserver.NewWebsocketManager(rpc.Routes, voterApp.EventSwitch())
+	mux.HandleFunc("/websocket", wm.WebsocketHandler)
 	rpcserver.RegisterRPCFuncs(mux, rpc.Routes)
-	_, err := rpcserver.StartHTTPServer("tcp://0.0.0.0:46680", mux)
+	_, err = rpcserver.StartHTTPServer("tcp://0.0.0.0:46680", mux)
 	if err != nil {
 		Exit(err.Error())
 	}
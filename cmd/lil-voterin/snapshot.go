@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/tendermint/go-common"
+	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-wire"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	sm "github.com/tendermint/lil-voterin/state"
+)
+
+// Write a snapshot of the app state at exportHeight to the snapshotFile, or stdout
+func ExportSnapshot() {
+	db := dbm.NewDB("lil-voterin-app", "leveldb", appDataDir)
+	state := sm.NewState(db)
+	if err := state.Load(); err != nil {
+		Exit("loading state: " + err.Error())
+	}
+	if exportHeight != 0 && exportHeight != state.GetHeight() {
+		var err error
+		state, err = state.LoadHeight(exportHeight)
+		if err != nil {
+			Exit("loading state: " + err.Error())
+		}
+	}
+
+	snap, err := state.Export()
+	if err != nil {
+		Exit("exporting state: " + err.Error())
+	}
+	jsonBytes := wire.JSONBytes(snap)
+	if snapshotFile == "" {
+		fmt.Println(string(jsonBytes))
+		return
+	}
+	if err := WriteFile(snapshotFile, jsonBytes, 0600); err != nil {
+		Exit("writing snapshot: " + err.Error())
+	}
+}
+
+// Save the state from the snapshotFile as the genesis state of a new app db,
+// and write the genesis of the new chain, with the snapshot's app hash and validators
+func ImportSnapshot() {
+	if snapshotFile == "" {
+		Exit("--snapshot is required")
+	}
+	if genesisChainID == "" {
+		Exit("--chain_id is required")
+	}
+	genesisFile := config.GetString("genesis_file")
+	if FileExists(genesisFile) {
+		Exit(Fmt("Genesis file %s already exists", genesisFile))
+	}
+	jsonBytes, err := ReadFile(snapshotFile)
+	if err != nil {
+		Exit("reading snapshot: " + err.Error())
+	}
+	snap := new(sm.Snapshot)
+	wire.ReadJSONPtr(snap, jsonBytes, &err)
+	if err != nil {
+		Exit("parsing snapshot JSON: " + err.Error())
+	}
+
+	db := dbm.NewDB("lil-voterin-app", "leveldb", appDataDir)
+	state, err := sm.ImportSnapshot(db, snap)
+	if err != nil {
+		Exit("importing snapshot: " + err.Error())
+	}
+	fmt.Printf("Imported the state from height %d with app hash %X\n", snap.Height, snap.AppHash)
+
+	genDoc, err := makeGenesisDoc(state)
+	if err != nil {
+		Exit("making genesis: " + err.Error())
+	}
+	if err := WriteFile(genesisFile, wire.JSONBytes(genDoc), 0644); err != nil {
+		Exit("writing genesis: " + err.Error())
+	}
+	fmt.Printf("Wrote the genesis of chain %s to %s\n", genDoc.ChainID, genesisFile)
+}
+
+// The new chain starts from the imported state, with its validators
+func makeGenesisDoc(state *sm.State) (*tmtypes.GenesisDoc, error) {
+	vals, err := state.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("Snapshot has no validators")
+	}
+	genDoc := &tmtypes.GenesisDoc{
+		GenesisTime: time.Now(),
+		ChainID:     genesisChainID,
+		AppHash:     state.GetAppHash(),
+	}
+	for _, v := range vals {
+		genDoc.Validators = append(genDoc.Validators, tmtypes.GenesisValidator{
+			PubKey: v.PubKey.PubKey,
+			Amount: int64(v.Power),
+		})
+	}
+	return genDoc, nil
+}
@@ -4,18 +4,96 @@ import (
 	"github.com/tendermint/lil-voterin/types"
 )
 
-func GetAccount(pubKey types.PubKey) (*ResultGetAccount, error) {
-	acc, err := voter.GetAccount(pubKey)
+// height 0 is the latest committed state
+func GetAccount(pubKey types.PubKey, height int) (*ResultGetAccount, error) {
+	state, err := voter.GetState(height)
 	if err != nil {
 		return nil, err
 	}
-	return &ResultGetAccount{*acc}, nil
+	acc, err := state.GetAccount(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := state.Proof(types.AccountKeyBytes(pubKey))
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetAccount{
+		Height:  state.GetHeight(),
+		Account: *acc,
+		Proof:   proof,
+	}, nil
 }
 
-func GetAccounts() (*ResultGetAccounts, error) {
-	accs, err := voter.GetAccounts()
+// an empty status returns every account
+func GetAccounts(status string) (*ResultGetAccounts, error) {
+	accStatus, err := types.ParseAccountStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	accs, err := voter.GetAccounts(accStatus)
 	return &ResultGetAccounts{
 		NumAccounts: len(accs),
 		Accounts:    accs,
 	}, err
 }
+
+// The election, account and eligibility list, with their proofs, show whether the voter is eligible.
+// A pubkey with no account is not eligible.
+// height 0 is the latest committed state
+func GetEligibility(pubKey types.PubKey, height int) (*ResultGetEligibility, error) {
+	state, err := voter.GetState(height)
+	if err != nil {
+		return nil, err
+	}
+	election := state.GetElection()
+	electionProof, err := state.Proof(types.ElectionKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	res := &ResultGetEligibility{
+		Height:        state.GetHeight(),
+		Election:      election,
+		ElectionProof: electionProof,
+	}
+
+	if res.AccountProof, err = state.KeyProof(types.AccountKeyBytes(pubKey)); err != nil {
+		return nil, err
+	}
+	if res.AccountProof.Exists {
+		if res.Account, err = state.GetAccount(pubKey); err != nil {
+			return nil, err
+		}
+		res.Eligible = state.IsEligible(pubKey, res.Account)
+	}
+
+	if res.VoterProof, err = state.KeyProof(types.EligibleKeyBytes(pubKey)); err != nil {
+		return nil, err
+	}
+	if res.Account != nil {
+		for _, tag := range res.Account.Tags {
+			tagProof, err := state.KeyProof(types.EligibleTagKeyBytes(tag))
+			if err != nil {
+				return nil, err
+			}
+			res.TagProofs = append(res.TagProofs, tagProof)
+		}
+	}
+	return res, nil
+}
+
+func GetAccountHistory(pubKey types.PubKey) (*ResultGetAccountHistory, error) {
+	history, err := voter.GetAccountHistory(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetAccountHistory{history}, nil
+}
+
+func GetDelegate(pubKey types.PubKey) (*ResultGetDelegate, error) {
+	delegate, effective, err := voter.GetDelegate(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetDelegate{delegate, effective}, nil
+}
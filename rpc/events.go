@@ -0,0 +1,24 @@
+package core
+
+import (
+	"github.com/tendermint/go-events"
+	rpctypes "github.com/tendermint/go-rpc/types"
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Send the named event to the websocket each time it fires.
+// The events are in types/events.go
+func Subscribe(wsCtx rpctypes.WSRPCContext, event string) (*ResultSubscribe, error) {
+	log.Notice("Subscribe to event", "remote", wsCtx.GetRemoteAddr(), "event", event)
+	wsCtx.GetEventSwitch().AddListenerForEvent(wsCtx.GetRemoteAddr(), event, func(msg events.EventData) {
+		result := LilVoterinResult(&ResultEvent{event, types.EventData(msg)})
+		wsCtx.TryWriteRPCResponse(rpctypes.NewRPCResponse(wsCtx.Request.ID+"#event", &result, ""))
+	})
+	return &ResultSubscribe{}, nil
+}
+
+func Unsubscribe(wsCtx rpctypes.WSRPCContext, event string) (*ResultUnsubscribe, error) {
+	log.Notice("Unsubscribe to event", "remote", wsCtx.GetRemoteAddr(), "event", event)
+	wsCtx.GetEventSwitch().RemoveListenerForEvent(event, wsCtx.GetRemoteAddr())
+	return &ResultUnsubscribe{}, nil
+}
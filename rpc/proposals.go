@@ -0,0 +1,17 @@
+package core
+
+func GetProposal(id int) (*ResultGetProposal, error) {
+	proposal, err := voter.GetProposal(id)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetProposal{proposal}, nil
+}
+
+func GetPendingProposals() (*ResultGetProposals, error) {
+	proposals, err := voter.GetPendingProposals()
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetProposals{proposals}, nil
+}
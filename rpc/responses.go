@@ -3,15 +3,26 @@ package core
 import (
 	"github.com/tendermint/go-rpc/types"
 	"github.com/tendermint/go-wire"
+	sm "github.com/tendermint/lil-voterin/state"
 	"github.com/tendermint/lil-voterin/types"
 )
 
 type ResultGetTally struct {
-	Tally *types.Tally `json:"tally"`
+	Height   int                     `json:"height"`
+	Tally    *types.Tally            `json:"tally"`
+	Results  []types.CandidateResult `json:"results"`
+	WriteIns []types.WriteInResult   `json:"write_ins"`
+	Proof    []byte                  `json:"proof"` // of the tally
+}
+
+type ResultGetOutcome struct {
+	Outcome *types.Outcome `json:"outcome"` // nil until the election is closed
 }
 
 type ResultGetAccount struct {
+	Height  int           `json:"height"`
 	Account types.Account `json:"account"`
+	Proof   []byte        `json:"proof"`
 }
 
 type ResultGetAccounts struct {
@@ -19,14 +30,75 @@ type ResultGetAccounts struct {
 	Accounts    []*types.PubAccount `json:"accounts"`
 }
 
+type ResultGetAccountHistory struct {
+	History types.AccountHistory `json:"history"`
+}
+
+type ResultGetEligibility struct {
+	Height   int  `json:"height"`
+	Eligible bool `json:"eligible"`
+
+	Election      *types.Election `json:"election"` // its proof shows if it restricts who can vote
+	ElectionProof []byte          `json:"election_proof"`
+
+	Account      *types.Account `json:"account"` // nil if there's no account
+	AccountProof *sm.KeyProof   `json:"account_proof"`
+
+	// proof the voter is on the eligibility list, or isn't,
+	// and of each of the account's tags
+	VoterProof *sm.KeyProof   `json:"voter_proof"`
+	TagProofs  []*sm.KeyProof `json:"tag_proofs"`
+}
+
+type ResultGetDelegate struct {
+	Delegate  *types.PubKey `json:"delegate"`
+	Effective *types.PubKey `json:"effective"`
+}
+
+type ResultGetValidators struct {
+	Validators []types.Validator `json:"validators"`
+}
+
+type ResultGetProposal struct {
+	Proposal *types.Proposal `json:"proposal"`
+}
+
+type ResultGetProposals struct {
+	Proposals []*types.Proposal `json:"proposals"`
+}
+
+type ResultSubscribe struct {
+}
+
+type ResultUnsubscribe struct {
+}
+
+type ResultEvent struct {
+	Name string          `json:"name"`
+	Data types.EventData `json:"data"`
+}
+
 //----------------------------------------
 // response & result types
 
 const (
-	ResultTypeGetTally = byte(0x01)
+	ResultTypeGetTally   = byte(0x01)
+	ResultTypeGetOutcome = byte(0x02)
+
+	ResultTypeGetAccount        = byte(0x10)
+	ResultTypeGetAccounts       = byte(0x11)
+	ResultTypeGetDelegate       = byte(0x12)
+	ResultTypeGetAccountHistory = byte(0x13)
+	ResultTypeGetEligibility    = byte(0x14)
+
+	ResultTypeGetValidators = byte(0x20)
+
+	ResultTypeGetProposal  = byte(0x30)
+	ResultTypeGetProposals = byte(0x31)
 
-	ResultTypeGetAccount  = byte(0x10)
-	ResultTypeGetAccounts = byte(0x11)
+	ResultTypeSubscribe   = byte(0x40)
+	ResultTypeUnsubscribe = byte(0x41)
+	ResultTypeEvent       = byte(0x42)
 )
 
 type LilVoterinResult interface {
@@ -37,6 +109,16 @@ type LilVoterinResult interface {
 var _ = wire.RegisterInterface(
 	struct{ LilVoterinResult }{},
 	wire.ConcreteType{&ResultGetTally{}, ResultTypeGetTally},
+	wire.ConcreteType{&ResultGetOutcome{}, ResultTypeGetOutcome},
 	wire.ConcreteType{&ResultGetAccount{}, ResultTypeGetAccount},
 	wire.ConcreteType{&ResultGetAccounts{}, ResultTypeGetAccounts},
+	wire.ConcreteType{&ResultGetDelegate{}, ResultTypeGetDelegate},
+	wire.ConcreteType{&ResultGetAccountHistory{}, ResultTypeGetAccountHistory},
+	wire.ConcreteType{&ResultGetEligibility{}, ResultTypeGetEligibility},
+	wire.ConcreteType{&ResultGetValidators{}, ResultTypeGetValidators},
+	wire.ConcreteType{&ResultGetProposal{}, ResultTypeGetProposal},
+	wire.ConcreteType{&ResultGetProposals{}, ResultTypeGetProposals},
+	wire.ConcreteType{&ResultSubscribe{}, ResultTypeSubscribe},
+	wire.ConcreteType{&ResultUnsubscribe{}, ResultTypeUnsubscribe},
+	wire.ConcreteType{&ResultEvent{}, ResultTypeEvent},
 )
@@ -2,33 +2,120 @@ package core
 
 import (
 	rpc "github.com/tendermint/go-rpc/server"
+	rpctypes "github.com/tendermint/go-rpc/types"
 	"github.com/tendermint/lil-voterin/types"
 )
 
 var Routes = map[string]*rpc.RPCFunc{
-	"get_tally":    rpc.NewRPCFunc(GetTallyResult, ""),
-	"get_account":  rpc.NewRPCFunc(GetAccountResult, "pubkey"),
-	"get_accounts": rpc.NewRPCFunc(GetAccountsResult, ""),
+	"get_tally":    rpc.NewRPCFunc(GetTallyResult, "height"),
+	"get_outcome":  rpc.NewRPCFunc(GetOutcomeResult, ""),
+	"get_account":  rpc.NewRPCFunc(GetAccountResult, "pubkey,height"),
+	"get_accounts": rpc.NewRPCFunc(GetAccountsResult, "status"),
+	"get_delegate": rpc.NewRPCFunc(GetDelegateResult, "pubkey"),
+
+	"get_account_history": rpc.NewRPCFunc(GetAccountHistoryResult, "pubkey"),
+	"get_eligibility":     rpc.NewRPCFunc(GetEligibilityResult, "pubkey,height"),
+
+	"get_validators": rpc.NewRPCFunc(GetValidatorsResult, ""),
+
+	"get_proposal":          rpc.NewRPCFunc(GetProposalResult, "id"),
+	"get_pending_proposals": rpc.NewRPCFunc(GetPendingProposalsResult, ""),
+
+	// websocket only
+	"subscribe":   rpc.NewWSRPCFunc(SubscribeResult, "event"),
+	"unsubscribe": rpc.NewWSRPCFunc(UnsubscribeResult, "event"),
+}
+
+func GetTallyResult(height int) (LilVoterinResult, error) {
+	if r, err := GetTally(height); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetOutcomeResult() (LilVoterinResult, error) {
+	if r, err := GetOutcome(); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetAccountResult(pubKey types.PubKey, height int) (LilVoterinResult, error) {
+	if r, err := GetAccount(pubKey, height); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetAccountsResult(status string) (LilVoterinResult, error) {
+	if r, err := GetAccounts(status); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetAccountHistoryResult(pubKey types.PubKey) (LilVoterinResult, error) {
+	if r, err := GetAccountHistory(pubKey); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetEligibilityResult(pubKey types.PubKey, height int) (LilVoterinResult, error) {
+	if r, err := GetEligibility(pubKey, height); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetDelegateResult(pubKey types.PubKey) (LilVoterinResult, error) {
+	if r, err := GetDelegate(pubKey); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetValidatorsResult() (LilVoterinResult, error) {
+	if r, err := GetValidators(); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
+}
+
+func GetProposalResult(id int) (LilVoterinResult, error) {
+	if r, err := GetProposal(id); err != nil {
+		return nil, err
+	} else {
+		return r, nil
+	}
 }
 
-func GetTallyResult() (LilVoterinResult, error) {
-	if r, err := GetTally(); err != nil {
+func GetPendingProposalsResult() (LilVoterinResult, error) {
+	if r, err := GetPendingProposals(); err != nil {
 		return nil, err
 	} else {
 		return r, nil
 	}
 }
 
-func GetAccountResult(pubKey types.PubKey) (LilVoterinResult, error) {
-	if r, err := GetAccount(pubKey); err != nil {
+func SubscribeResult(wsCtx rpctypes.WSRPCContext, event string) (LilVoterinResult, error) {
+	if r, err := Subscribe(wsCtx, event); err != nil {
 		return nil, err
 	} else {
 		return r, nil
 	}
 }
 
-func GetAccountsResult() (LilVoterinResult, error) {
-	if r, err := GetAccounts(); err != nil {
+func UnsubscribeResult(wsCtx rpctypes.WSRPCContext, event string) (LilVoterinResult, error) {
+	if r, err := Unsubscribe(wsCtx, event); err != nil {
 		return nil, err
 	} else {
 		return r, nil
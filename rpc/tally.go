@@ -1,6 +1,37 @@
 package core
 
-func GetTally() (*ResultGetTally, error) {
-	tally := voter.GetTally()
-	return &ResultGetTally{tally}, nil
+import (
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// height 0 is the latest committed state
+func GetTally(height int) (*ResultGetTally, error) {
+	state, err := voter.GetState(height)
+	if err != nil {
+		return nil, err
+	}
+	tally := state.GetTally()
+	writeIns, err := state.GetWriteIns()
+	if err != nil {
+		return nil, err
+	}
+	proof, err := state.Proof(types.TallyKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetTally{
+		Height:   state.GetHeight(),
+		Tally:    tally,
+		Results:  state.GetElection().Results(tally),
+		WriteIns: writeIns,
+		Proof:    proof,
+	}, nil
+}
+
+func GetOutcome() (*ResultGetOutcome, error) {
+	outcome, err := voter.GetOutcome()
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetOutcome{outcome}, nil
 }
@@ -0,0 +1,9 @@
+package core
+
+func GetValidators() (*ResultGetValidators, error) {
+	vals, err := voter.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	return &ResultGetValidators{vals}, nil
+}
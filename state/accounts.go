@@ -69,6 +69,53 @@ func (accounts *Accounts) SetAccount(pubKey types.PubKey, acc *types.Account) er
 	return nil
 }
 
+// Mark the account for removal from the tree on the next sync
+func (accounts *Accounts) RemoveAccount(pubKey types.PubKey) {
+	accounts.cache[types.AccountKeyString(pubKey)] = nil
+}
+
+// Call fn for each account in key order, with the changes in the cache.
+// Stops early if fn returns true or an account can't be decoded
+func (accounts *Accounts) Iterate(fn func(pubKey types.PubKey, acc *types.Account) (stop bool)) error {
+	var keys []string
+	inTree := make(map[string][]byte)
+	accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if types.IsAccountKey(key) {
+			keys = append(keys, string(key))
+			inTree[string(key)] = value
+		}
+		return false
+	})
+	for k, _ := range accounts.cache {
+		if _, ok := inTree[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		pubKey, err := types.PubKeyFromBytes([]byte(k[len(types.AccountKeyPrefix):]))
+		if err != nil {
+			return err
+		}
+		acc, ok := accounts.cache[k]
+		if !ok {
+			acc = new(types.Account)
+			if err := acc.Unmarshal(inTree[k]); err != nil {
+				return err
+			}
+		}
+		// removed in this block
+		if acc == nil {
+			continue
+		}
+		if fn(pubKey, acc) {
+			return nil
+		}
+	}
+	return nil
+}
+
 // sync cache to merkle tree
 func (accounts *Accounts) Sync() {
 	keys := []string{}
@@ -77,7 +124,11 @@ func (accounts *Accounts) Sync() {
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		accounts.setAccount(types.BytesToAccountKey([]byte(k)), accounts.cache[k])
+		if acc := accounts.cache[k]; acc == nil {
+			accounts.tree.Remove([]byte(k))
+		} else {
+			accounts.setAccount(types.BytesToAccountKey([]byte(k)), acc)
+		}
 	}
 }
 
@@ -0,0 +1,52 @@
+package state
+
+import (
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/lil-voterin/types"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+// Load the account and check it has the permissions.
+// Every tx is authorised through here
+func authorize(state *State, pubKey types.PubKey, perm types.Permission) (*types.Account, tmsp.Result) {
+	acc, err := state.GetAccount(pubKey)
+	if err != nil {
+		return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting account %X: %v", pubKey, err))
+	}
+	if acc.IsSuspended(state.GetBlockHeight()) {
+		return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is suspended: %s", pubKey, acc.Suspension.Reason))
+	}
+	if !acc.Can(perm) {
+		missing := perm &^ acc.Type.Permissions()
+		return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is type %v, without permission %v", pubKey, acc.Type, missing))
+	}
+	return acc, tmsp.OK
+}
+
+// The permissions needed to make the changes in an AdminTx or a proposal.
+// Changing an account needs permission to manage both its old and new type,
+// and suspending, reinstating or deleting it needs permission to manage its type.
+// An empty payload changes nothing, but still needs an admin
+func payloadPermissions(state *State, payload *types.AdminPayload) types.Permission {
+	var perm types.Permission
+	for _, pubAcc := range payload.PubAccounts {
+		if pubAcc.Account != nil {
+			perm |= pubAcc.Account.Type.ManagePermission()
+		}
+		if acc, err := state.GetAccount(pubAcc.PubKey); err == nil {
+			perm |= acc.Type.ManagePermission()
+		}
+	}
+	for _, action := range payload.AccountActions {
+		if acc, err := state.GetAccount(action.PubKey); err == nil {
+			perm |= acc.Type.ManagePermission()
+		}
+	}
+	if payload.ChangesElection() {
+		perm |= types.PermManageElection
+	}
+	if perm == 0 {
+		perm = types.PermManageRoles
+	}
+	return perm
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Per voter ballot records are written directly to the merkle tree.
+// The ballots counted for a voter's last VoteTx are kept so the tally
+// can be corrected if the election allows replacing ballots.
+// The number of ballots cast is kept to enforce the account's quota,
+// and the number in the tally to count turnout
+
+// Add the ballot to the tally, and count its write-in.
+// The ballot must already be validated against the election
+func (s *State) AddBallot(ballot types.Ballot) error {
+	if err := s.tally.AddBallot(ballot); err != nil {
+		return err
+	}
+	return s.addWriteIn(ballot.WriteIn, 1)
+}
+
+// Remove a ballot that was previously added
+func (s *State) RemoveBallot(ballot types.Ballot) error {
+	if err := s.tally.RemoveBallot(ballot); err != nil {
+		return err
+	}
+	return s.addWriteIn(ballot.WriteIn, -1)
+}
+
+func (s *State) addWriteIn(writeIn string, diff int64) error {
+	if writeIn == "" {
+		return nil
+	}
+	name := types.NormalizeWriteIn(writeIn)
+	count, err := s.GetWriteIn(name)
+	if err != nil {
+		return err
+	}
+	s.SetWriteIn(name, count+diff)
+	return nil
+}
+
+// Return the ballots counted for the voter's last VoteTx, if any
+func (s *State) GetBallots(pubKey types.PubKey) ([]types.Ballot, error) {
+	_, ballotsBytes, exists := s.accounts.tree.Get(types.BallotsKeyBytes(pubKey))
+	if !exists || len(ballotsBytes) == 0 {
+		return nil, nil
+	}
+	return types.UnmarshalBallots(ballotsBytes)
+}
+
+func (s *State) SetBallots(pubKey types.PubKey, ballots []types.Ballot) {
+	s.accounts.tree.Set(types.BallotsKeyBytes(pubKey), types.MarshalBallots(ballots))
+}
+
+// Return the total number of ballots the voter has cast
+func (s *State) GetBallotCount(pubKey types.PubKey) (int, error) {
+	_, countBytes, exists := s.accounts.tree.Get(types.BallotCountKeyBytes(pubKey))
+	if !exists || len(countBytes) == 0 {
+		return 0, nil
+	}
+	var count int
+	err := wire.ReadBinaryBytes(countBytes, &count)
+	return count, err
+}
+
+func (s *State) SetBallotCount(pubKey types.PubKey, count int) {
+	s.accounts.tree.Set(types.BallotCountKeyBytes(pubKey), wire.BinaryBytes(count))
+}
+
+// Return the number of the voter's ballots in the tally
+func (s *State) GetCountedBallots(pubKey types.PubKey) (int, error) {
+	_, countBytes, exists := s.accounts.tree.Get(types.CountedBallotsKeyBytes(pubKey))
+	if !exists || len(countBytes) == 0 {
+		return 0, nil
+	}
+	var count int
+	err := wire.ReadBinaryBytes(countBytes, &count)
+	return count, err
+}
+
+// A count of zero removes it
+func (s *State) SetCountedBallots(pubKey types.PubKey, count int) {
+	if count == 0 {
+		s.accounts.tree.Remove(types.CountedBallotsKeyBytes(pubKey))
+		return
+	}
+	s.accounts.tree.Set(types.CountedBallotsKeyBytes(pubKey), wire.BinaryBytes(count))
+}
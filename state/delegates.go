@@ -0,0 +1,117 @@
+package state
+
+import (
+	"bytes"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Delegations are written directly to the merkle tree.
+// A voter who has not voted directly is counted with the ballots of
+// the first voter along their chain of delegates who has.
+// Delegations are resolved when the election is closed
+
+// Return the voter's direct delegate, if any
+func (s *State) GetDelegate(pubKey types.PubKey) (*types.PubKey, error) {
+	_, delegateBytes, exists := s.accounts.tree.Get(types.DelegateKeyBytes(pubKey))
+	if !exists || len(delegateBytes) == 0 {
+		return nil, nil
+	}
+	delegate := types.BytesToPubKey(delegateBytes)
+	return &delegate, nil
+}
+
+// Set the voter's delegate. A nil delegate removes the delegation
+func (s *State) SetDelegate(pubKey types.PubKey, delegate *types.PubKey) {
+	if delegate == nil {
+		s.accounts.tree.Remove(types.DelegateKeyBytes(pubKey))
+		return
+	}
+	s.accounts.tree.Set(types.DelegateKeyBytes(pubKey), delegate.Bytes())
+}
+
+// Returns true if the voter has cast a VoteTx
+func (s *State) HasVoted(pubKey types.PubKey) bool {
+	return s.accounts.tree.Has(types.BallotsKeyBytes(pubKey))
+}
+
+// Follow the chain of delegates from the voter to the first who has voted.
+// Returns nil if the voter has not delegated or nobody on the chain has voted.
+// The voter's own vote is ignored
+func (s *State) GetEffectiveDelegate(pubKey types.PubKey) (*types.PubKey, error) {
+	visited := map[types.PubKey]struct{}{pubKey: struct{}{}}
+	delegate, err := s.GetDelegate(pubKey)
+	for ; delegate != nil && err == nil; delegate, err = s.GetDelegate(*delegate) {
+		// cycles are rejected by ExecDelegateTx, but be safe
+		if _, ok := visited[*delegate]; ok {
+			return nil, nil
+		}
+		visited[*delegate] = struct{}{}
+		if s.HasVoted(*delegate) {
+			return delegate, nil
+		}
+	}
+	return nil, err
+}
+
+// Returns true if delegating from pubKey to delegate would create a cycle
+func (s *State) IsDelegationCycle(pubKey, delegate types.PubKey) (bool, error) {
+	visited := make(map[types.PubKey]struct{})
+	next := &delegate
+	var err error
+	for ; next != nil && err == nil; next, err = s.GetDelegate(*next) {
+		if *next == pubKey {
+			return true, nil
+		}
+		if _, ok := visited[*next]; ok {
+			// an existing cycle that doesn't involve pubKey
+			return true, nil
+		}
+		visited[*next] = struct{}{}
+	}
+	return false, err
+}
+
+// Returns the ballots of each voter's effective delegate,
+// for voters who did not vote directly, to add to the tally,
+// and the number of voters counted this way. The state isn't changed
+func (s *State) resolveDelegations() ([]types.Ballot, int, error) {
+	var delegators []types.PubKey
+	s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if bytes.HasPrefix(key, types.DelegateKeyPrefix) {
+			delegators = append(delegators, types.BytesToPubKey(key[len(types.DelegateKeyPrefix):]))
+		}
+		return false
+	})
+
+	var delegated []types.Ballot
+	var counted int
+	for _, delegator := range delegators {
+		// voting directly overrides the delegation
+		if s.HasVoted(delegator) {
+			continue
+		}
+		// the voter may have been disabled, suspended
+		// or made ineligible since the delegation
+		acc, err := s.GetAccount(delegator)
+		if err != nil || !acc.Can(types.PermVote) || acc.IsSuspended(s.GetBlockHeight()) ||
+			!s.IsEligible(delegator, acc) {
+			continue
+		}
+		delegate, err := s.GetEffectiveDelegate(delegator)
+		if err != nil {
+			return nil, 0, err
+		}
+		if delegate == nil {
+			continue
+		}
+		ballots, err := s.GetBallots(*delegate)
+		if err != nil {
+			return nil, 0, err
+		}
+		// these were counted for the delegate, so they are valid
+		delegated = append(delegated, ballots...)
+		counted += 1
+	}
+	return delegated, counted, nil
+}
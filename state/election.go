@@ -0,0 +1,188 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Close the election. Delegated votes are added to the tally.
+// If none of the above wins and the election asks for it,
+// the ballots are cleared and the election stays open for a re-run.
+// Otherwise the outcome is evaluated and recorded.
+// The state is only changed if there's no error
+func (s *State) CloseElection(closedBy types.PubKey) error {
+	changes, err := s.prepareClose(closedBy)
+	if err != nil {
+		return err
+	}
+	s.applyClose(changes)
+	return nil
+}
+
+// The result of closing the election,
+// computed before anything is written to the state
+type closeChanges struct {
+	election *types.Election
+	rerun    bool                // clear the ballots instead of recording an outcome
+	tally    *types.Tally        // with the delegated ballots
+	writeIns map[string]int64    // new count for each write-in with delegated ballots
+	outcome  *types.Outcome
+}
+
+func (s *State) prepareClose(closedBy types.PubKey) (*closeChanges, error) {
+	election := s.GetElection().Copy()
+	if election.Closed {
+		return nil, fmt.Errorf("Election is already closed")
+	}
+	height := s.GetBlockHeight()
+	changes := &closeChanges{
+		election: election,
+		tally:    s.GetTally().Copy(),
+		writeIns: make(map[string]int64),
+	}
+
+	// count the delegated votes
+	delegatedBallots, delegated, err := s.resolveDelegations()
+	if err != nil {
+		return nil, fmt.Errorf("Error resolving delegations: %v", err)
+	}
+	for _, ballot := range delegatedBallots {
+		if err := changes.tally.AddBallot(ballot); err != nil {
+			return nil, fmt.Errorf("Error adding a delegated ballot: %v", err)
+		}
+		if ballot.WriteIn == "" {
+			continue
+		}
+		name := types.NormalizeWriteIn(ballot.WriteIn)
+		count, ok := changes.writeIns[name]
+		if !ok {
+			if count, err = s.GetWriteIn(name); err != nil {
+				return nil, err
+			}
+		}
+		changes.writeIns[name] = count + 1
+	}
+
+	if election.RerunOnNoneOfTheAbove && changes.tally.NoneOfTheAboveWins() {
+		changes.rerun = true
+		election.Reruns += 1
+		if election.CloseHeight > 0 && election.CloseHeight <= height {
+			// closed on schedule, so schedule the rerun's close
+			// for as long after now as the first run was open
+			period := election.CloseHeight - election.OpenHeight
+			if period < 1 {
+				period = 1
+			}
+			election.CloseHeight = height + period
+		}
+		return changes, nil
+	}
+
+	// evaluate the outcome
+	voted, eligible, err := s.countTurnout()
+	if err != nil {
+		return nil, fmt.Errorf("Error counting turnout: %v", err)
+	}
+	changes.outcome = election.Evaluate(changes.tally, voted+delegated, eligible)
+	changes.outcome.ClosedBy = closedBy
+
+	election.Closed = true
+	election.ClosedHeight = height
+	return changes, nil
+}
+
+func (s *State) applyClose(changes *closeChanges) {
+	height := s.GetBlockHeight()
+	if changes.rerun {
+		s.resetBallots()
+		s.SetElection(changes.election)
+		s.AddEvent(types.EventElectionRerun, types.EventDataElection{
+			Height:   height,
+			Election: changes.election.Copy(),
+		})
+		return
+	}
+
+	// sort so the tree is deterministic
+	names := make([]string, 0, len(changes.writeIns))
+	for name, _ := range changes.writeIns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.SetWriteIn(name, changes.writeIns[name])
+	}
+	s.SetTally(changes.tally)
+	s.setOutcome(changes.outcome)
+
+	s.SetElection(changes.election)
+	s.AddEvent(types.EventElectionClosed, types.EventDataElection{
+		Height:   height,
+		Election: changes.election.Copy(),
+		Outcome:  changes.outcome,
+	})
+}
+
+// Return the outcome recorded when the election was closed, if any
+func (s *State) GetOutcome() (*types.Outcome, error) {
+	_, outcomeBytes, exists := s.accounts.tree.Get(types.OutcomeKeyBytes)
+	if !exists || len(outcomeBytes) == 0 {
+		return nil, nil
+	}
+	outcome := new(types.Outcome)
+	err := outcome.Unmarshal(outcomeBytes)
+	return outcome, err
+}
+
+func (s *State) setOutcome(outcome *types.Outcome) {
+	s.accounts.tree.Set(types.OutcomeKeyBytes, outcome.Marshal())
+}
+
+// Number of eligible voters with at least one ballot in the tally,
+// and the number of eligible voters
+func (s *State) countTurnout() (voted, eligible int, err error) {
+	height := s.GetBlockHeight()
+	var countErr error
+	err = s.accounts.Iterate(func(pubKey types.PubKey, acc *types.Account) (stop bool) {
+		if !acc.Can(types.PermVote) || acc.IsSuspended(height) || !s.IsEligible(pubKey, acc) {
+			return false
+		}
+		eligible += 1
+		counted, err := s.GetCountedBallots(pubKey)
+		if err != nil {
+			countErr = err
+			return true
+		}
+		if counted > 0 {
+			voted += 1
+		}
+		return false
+	})
+	if err == nil {
+		err = countErr
+	}
+	return voted, eligible, err
+}
+
+// Clear the tally and everything recorded about voters' ballots.
+// Delegations are kept
+func (s *State) resetBallots() {
+	var keys [][]byte
+	s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if bytes.HasPrefix(key, types.BallotsKeyPrefix) ||
+			bytes.HasPrefix(key, types.BallotCountKeyPrefix) ||
+			bytes.HasPrefix(key, types.CountedBallotsKeyPrefix) ||
+			bytes.HasPrefix(key, types.WriteInKeyPrefix) {
+			keys = append(keys, key)
+		}
+		return false
+	})
+	for _, key := range keys {
+		s.accounts.tree.Remove(key)
+	}
+	s.SetTally(types.NewTally(len(s.GetElection().Candidates)))
+	s.accounts.tree.Remove(types.OutcomeKeyBytes)
+}
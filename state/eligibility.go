@@ -0,0 +1,101 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Returns true if the account may vote in the election.
+// It must still be able to vote
+func (s *State) IsEligible(pubKey types.PubKey, acc *types.Account) bool {
+	if !s.GetElection().IsRestricted() {
+		return true
+	}
+	if s.HasEligibleVoter(pubKey) {
+		return true
+	}
+	for _, tag := range acc.Tags {
+		if s.accounts.tree.Has(types.EligibleTagKeyBytes(tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the voter is on the eligibility list
+func (s *State) HasEligibleVoter(pubKey types.PubKey) bool {
+	return s.accounts.tree.Has(types.EligibleKeyBytes(pubKey))
+}
+
+// the keys to add to or remove from the eligibility list, in order
+type eligibilityChange struct {
+	key    []byte
+	remove bool
+}
+
+// Check the actions against the eligibility list and count the change in the election
+func (s *State) prepareEligibility(election *types.Election, actions []types.EligibilityAction) ([]eligibilityChange, error) {
+	var changes []eligibilityChange
+	// keys changed by earlier actions
+	pending := make(map[string]bool)
+	has := func(key []byte) bool {
+		if listed, ok := pending[string(key)]; ok {
+			return listed
+		}
+		return s.accounts.tree.Has(key)
+	}
+	change := func(key []byte, remove bool) {
+		pending[string(key)] = !remove
+		changes = append(changes, eligibilityChange{key, remove})
+	}
+
+	for _, action := range actions {
+		for _, pubKey := range action.Voters {
+			key := types.EligibleKeyBytes(pubKey)
+			if action.Remove {
+				if !has(key) {
+					return nil, fmt.Errorf("Voter %X is not on the eligibility list", pubKey)
+				}
+				election.EligibleVoters -= 1
+			} else {
+				if has(key) {
+					return nil, fmt.Errorf("Voter %X is already eligible", pubKey)
+				}
+				election.EligibleVoters += 1
+				election.Restricted = true
+			}
+			change(key, action.Remove)
+		}
+		for _, tag := range action.Tags {
+			if err := types.ValidateTag(tag); err != nil {
+				return nil, err
+			}
+			key := types.EligibleTagKeyBytes(tag)
+			if action.Remove {
+				if !has(key) {
+					return nil, fmt.Errorf("Tag %q is not eligible", tag)
+				}
+				election.EligibleTags -= 1
+			} else {
+				if has(key) {
+					return nil, fmt.Errorf("Tag %q is already eligible", tag)
+				}
+				election.EligibleTags += 1
+				election.Restricted = true
+			}
+			change(key, action.Remove)
+		}
+	}
+	return changes, nil
+}
+
+func (s *State) applyEligibility(changes []eligibilityChange) {
+	for _, c := range changes {
+		if c.remove {
+			s.accounts.tree.Remove(c.key)
+		} else {
+			s.accounts.tree.Set(c.key, []byte{1})
+		}
+	}
+}
@@ -16,6 +16,11 @@ const maxTxSize = 2048 // 2KB
 
 // unmarshal the tx and execute it
 func ExecTxBytes(state *State, txBytes []byte, appendTx bool) (res tmsp.Result) {
+	return ExecTxBytesWith(state, txBytes, appendTx, types.VerifyNow)
+}
+
+// execute the tx, with its signatures checked by v
+func ExecTxBytesWith(state *State, txBytes []byte, appendTx bool, v types.Verifier) (res tmsp.Result) {
 	if len(txBytes) > maxTxSize {
 		return tmsp.ErrEncodingError.AppendLog("Tx size exceeds maximum")
 	}
@@ -31,8 +36,15 @@ func ExecTxBytes(state *State, txBytes []byte, appendTx bool) (res tmsp.Result)
 	tx = txI.(struct {
 		types.Tx `json:"unwrap"`
 	}).Tx
+	// the signer may be named by account index
+	if itx, ok := tx.(types.IndexedTx); ok {
+		if err := state.resolveSigner(itx); err != nil {
+			return tmsp.ErrUnauthorized.AppendLog(err.Error())
+		}
+	}
+
 	// Validate tx
-	res = tx.Validate()
+	res = tx.ValidateWith(v)
 	if !res.IsOK() {
 		return res
 	}
@@ -50,6 +62,18 @@ func ExecTx(state *State, tx types.Tx, appendTx bool) (res tmsp.Result) {
 		return ExecAdminTx(state, tx_, appendTx)
 	case *types.ForkTx:
 		return ExecForkTx(state, tx_, appendTx)
+	case *types.DelegateTx:
+		return ExecDelegateTx(state, tx_, appendTx)
+	case *types.CloseTx:
+		return ExecCloseTx(state, tx_, appendTx)
+	case *types.ValidatorTx:
+		return ExecValidatorTx(state, tx_, appendTx)
+	case *types.ProposeAdminTx:
+		return ExecProposeAdminTx(state, tx_, appendTx)
+	case *types.ApproveTx:
+		return ExecApproveTx(state, tx_, appendTx)
+	case *types.RotateKeyTx:
+		return ExecRotateKeyTx(state, tx_, appendTx)
 	}
 	// NOTE: tx should already by decoded properly and be one of the above
 	// so this should never happen
@@ -57,75 +81,448 @@ func ExecTx(state *State, tx types.Tx, appendTx bool) (res tmsp.Result) {
 }
 
 func ExecVoteTx(state *State, tx *types.VoteTx, appendTx bool) tmsp.Result {
-	// load account
-	acc, err := state.GetAccount(tx.PubKey)
-	if err != nil {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting account %X: %v", tx.PubKey, err))
+	// named by the pubkey or the account index
+	pubKey := *tx.PubKey
+
+	// load account and check it can vote
+	acc, res := authorize(state, pubKey, types.PermVote)
+	if !res.IsOK() {
+		return res
+	}
+
+	// check the election is open
+	election := state.GetElection()
+	if !election.IsOpen(state.GetBlockHeight()) {
+		return tmsp.ErrUnauthorized.AppendLog("Election is not open")
+	}
+	if !state.IsEligible(pubKey, acc) {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is not eligible to vote in this election", pubKey))
 	}
 
-	// check account is voter type
-	if acc.Type != types.AccountTypeVoter {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is type %v, not voter (%v)", tx.PubKey, acc.Type, types.AccountTypeVoter))
+	if election.MaxBallotsPerTx > 0 && len(tx.Ballots) > election.MaxBallotsPerTx {
+		return types.ErrBadBallot.AppendLog(Fmt("Tx has %d ballots. Max is %d", len(tx.Ballots), election.MaxBallotsPerTx))
+	}
+
+	// in strict mode, every ballot must be counted
+	if election.StrictBallots {
+		tally := state.GetTally().Copy()
+		for i, ballot := range tx.Ballots {
+			err := election.ValidateBallot(ballot)
+			if err == nil {
+				err = tally.AddBallot(ballot)
+			}
+			if err != nil {
+				return types.ErrBadBallot.AppendLog(Fmt("Ballot %d: %v", i, err))
+			}
+		}
+	}
+
+	// check the voter has enough of their quota left
+	ballotCount, err := state.GetBallotCount(pubKey)
+	if err != nil {
+		return tmsp.ErrInternalError.AppendLog(Fmt("Error getting ballot count for %X: %v", pubKey, err))
+	}
+	if acc.BallotQuota > 0 && ballotCount+len(tx.Ballots) > acc.BallotQuota {
+		return types.ErrBallotQuotaExceeded.AppendLog(Fmt("Account %X has cast %d of its %d ballots, tx has %d more", pubKey, ballotCount, acc.BallotQuota, len(tx.Ballots)))
 	}
 
 	// check tx.Nonce not already used
-	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+	if !state.AddNonce(pubKey, tx.Nonce) {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
 	}
 
+	countedBallots, err := state.GetCountedBallots(pubKey)
+	if err != nil {
+		return tmsp.ErrInternalError.AppendLog(Fmt("Error getting counted ballots for %X: %v", pubKey, err))
+	}
+
+	// remove the voter's previous ballots
+	if election.ReplaceBallots {
+		prevBallots, err := state.GetBallots(pubKey)
+		if err != nil {
+			return tmsp.ErrInternalError.AppendLog(Fmt("Error getting ballots for %X: %v", pubKey, err))
+		}
+		for _, ballot := range prevBallots {
+			// these were counted, so they can be removed
+			state.RemoveBallot(ballot)
+		}
+		countedBallots = 0
+	}
+
 	// add ballots
-	tally := state.GetTally()
+	counted := make([]types.Ballot, 0, len(tx.Ballots))
 	for _, ballot := range tx.Ballots {
 		// XXX: bad ballots do not cause an error
 		// but do not effect the tally
-		tally.AddBallot(ballot)
+		if err := election.ValidateBallot(ballot); err != nil {
+			continue
+		}
+		if err := state.AddBallot(ballot); err == nil {
+			counted = append(counted, ballot)
+		}
 	}
-	state.SetTally(tally)
+
+	// remember what was counted so it can be replaced
+	if election.ReplaceBallots {
+		state.SetBallots(pubKey, counted)
+	}
+
+	// bad ballots still count against the quota, but not for turnout
+	state.SetBallotCount(pubKey, ballotCount+len(tx.Ballots))
+	state.SetCountedBallots(pubKey, countedBallots+len(counted))
 
 	// increment account sequence number
 	acc.Sequence += 1
-	// state.SetAccount(tx.PubKey, acc)
+	// state.SetAccount(pubKey, acc)
 
 	return tmsp.OK
 }
 
 func ExecAdminTx(state *State, tx *types.AdminTx, appendTx bool) tmsp.Result {
-	// load account
-	acc, err := state.GetAccount(tx.PubKey)
-	if err != nil {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting account %X: %v", tx.PubKey, err))
+	// named by the pubkey or the account index
+	pubKey := *tx.PubKey
+
+	// load account and check it can make the changes
+	acc, res := authorize(state, pubKey, payloadPermissions(state, tx.Payload()))
+	if !res.IsOK() {
+		return res
 	}
 
-	// check account is admin type
-	if acc.Type != types.AccountTypeAdmin {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is type %v, not admin (%v)", tx.PubKey, acc.Type, types.AccountTypeAdmin))
+	changes, res := prepareAdminPayload(state, tx.Payload(), pubKey)
+	if !res.IsOK() {
+		return res
 	}
 
 	// check tx.Nonce not already used
-	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+	if !state.AddNonce(pubKey, tx.Nonce) {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
 	}
 
+	applyAdminPayload(state, changes)
+
+	acc.Sequence += 1
+
+	return tmsp.OK
+}
+
+// the changes made by an AdminTx or a proposal,
+// checked before they are applied
+type adminChanges struct {
+	payload     *types.AdminPayload
+	pubAccounts []*types.PubAccount // with the suspensions they have now
+	election    *types.Election
+	eligibility []eligibilityChange
+	writeIns    *writeInChanges
+	accounts    []accountChange
+}
+
+// by is recorded in the history of accounts the payload suspends, reinstates or deletes
+func prepareAdminPayload(state *State, payload *types.AdminPayload, by types.PubKey) (*adminChanges, tmsp.Result) {
+	// checked again for proposals made before it was
+	if err := payload.Validate(); err != nil {
+		return nil, tmsp.ErrEncodingError.AppendLog(err.Error())
+	}
+	changes := &adminChanges{payload: payload}
+	for _, pubAcc := range payload.PubAccounts {
+		for _, tag := range pubAcc.Account.Tags {
+			if err := types.ValidateTag(tag); err != nil {
+				return nil, tmsp.ErrEncodingError.AppendLog(err.Error())
+			}
+		}
+		if newKey := state.GetRotatedKey(pubAcc.PubKey); newKey != nil {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Key %X was rotated to %X", pubAcc.PubKey, *newKey))
+		}
+
+		// suspensions are only changed by account actions, which record them in the history.
+		// an account that's set again keeps its suspension
+		if pubAcc.Account.Suspension != nil {
+			return nil, tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X can only be suspended by an account action", pubAcc.PubKey))
+		}
+		newAcc := new(types.Account)
+		*newAcc = *pubAcc.Account
+		if acc, err := state.GetAccount(pubAcc.PubKey); err == nil {
+			newAcc.Suspension = acc.Suspension
+		}
+		changes.pubAccounts = append(changes.pubAccounts, &types.PubAccount{pubAcc.PubKey, newAcc})
+	}
+	if payload.ChangesElection() {
+		changes.election = state.GetElection().Copy()
+	}
+	election := changes.election
+
+	// set consensus options
+	for _, opt := range payload.Options {
+		if err := election.SetOption(opt.Key, opt.Value); err != nil {
+			return nil, types.ErrBadOption.AppendLog(err.Error())
+		}
+	}
+
+	// register candidates
+	if len(payload.Candidates) > 0 {
+		if election.Closed {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Election is closed")
+		}
+		for _, c := range payload.Candidates {
+			if _, err := election.AddCandidate(c); err != nil {
+				return nil, types.ErrBadCandidate.AppendLog(err.Error())
+			}
+		}
+	}
+
+	// restrict who can vote
+	if len(payload.Eligibility) > 0 {
+		if election.Closed {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Election is closed")
+		}
+		eligibility, err := state.prepareEligibility(election, payload.Eligibility)
+		if err != nil {
+			return nil, types.ErrBadEligibility.AppendLog(err.Error())
+		}
+		changes.eligibility = eligibility
+	}
+
+	// merge and promote write-ins
+	if len(payload.WriteIns) > 0 {
+		if !election.Closed {
+			return nil, tmsp.ErrUnauthorized.AppendLog("Write-ins can only be moved after the election is closed")
+		}
+		writeIns, err := state.prepareWriteIns(election, payload.WriteIns)
+		if err != nil {
+			return nil, types.ErrBadCandidate.AppendLog(err.Error())
+		}
+		changes.writeIns = writeIns
+	}
+
+	// suspend, reinstate and delete accounts
+	if len(payload.AccountActions) > 0 {
+		accounts, err := state.prepareAccountActions(payload, by)
+		if err != nil {
+			return nil, types.ErrBadAccountAction.AppendLog(err.Error())
+		}
+		changes.accounts = accounts
+	}
+	return changes, tmsp.OK
+}
+
+func applyAdminPayload(state *State, changes *adminChanges) {
 	// update accounts
-	for _, pubAcc := range tx.PubAccounts {
+	for _, pubAcc := range changes.pubAccounts {
 		state.SetAccount(pubAcc.PubKey, pubAcc.Account)
 	}
+	state.applyAccountActions(changes.accounts)
+	state.applyEligibility(changes.eligibility)
+
+	if changes.writeIns != nil {
+		state.applyWriteIns(changes.writeIns)
+	} else if changes.election != nil {
+		state.SetElection(changes.election)
+	}
+}
+
+func ExecForkTx(state *State, tx *types.ForkTx, appendTx bool) tmsp.Result {
+	// named by the pubkey or the account index
+	pubKey := *tx.PubKey
+
+	// load account and check it can fork
+	acc, res := authorize(state, pubKey, types.PermFork)
+	if !res.IsOK() {
+		return res
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(pubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
 
 	acc.Sequence += 1
 
 	return tmsp.OK
 }
 
-func ExecForkTx(state *State, tx *types.ForkTx, appendTx bool) tmsp.Result {
-	// load account
-	acc, err := state.GetAccount(tx.PubKey)
+func ExecDelegateTx(state *State, tx *types.DelegateTx, appendTx bool) tmsp.Result {
+	// load account and check it can vote
+	acc, res := authorize(state, tx.PubKey, types.PermVote)
+	if !res.IsOK() {
+		return res
+	}
+
+	// delegates vote with the ballots from their last VoteTx,
+	// which are only kept if ballots are replaced
+	election := state.GetElection()
+	if !election.ReplaceBallots {
+		return tmsp.ErrUnauthorized.AppendLog("Election does not replace ballots, so votes cannot be delegated")
+	}
+	if !election.IsOpen(state.GetBlockHeight()) {
+		return tmsp.ErrUnauthorized.AppendLog("Election is not open")
+	}
+	if !state.IsEligible(tx.PubKey, acc) {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is not eligible to vote in this election", tx.PubKey))
+	}
+
+	if tx.Delegate != nil {
+		// check the delegate is a voter
+		delegateAcc, err := state.GetAccount(*tx.Delegate)
+		if err != nil {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting delegate account %X: %v", *tx.Delegate, err))
+		}
+		if !delegateAcc.Can(types.PermVote) {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Delegate %X is type %v, without permission %v", *tx.Delegate, delegateAcc.Type, types.PermVote))
+		}
+		if delegateAcc.IsSuspended(state.GetBlockHeight()) {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Delegate %X is suspended", *tx.Delegate))
+		}
+		if !state.IsEligible(*tx.Delegate, delegateAcc) {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Delegate %X is not eligible to vote in this election", *tx.Delegate))
+		}
+
+		// check the delegation doesn't create a cycle
+		cycle, err := state.IsDelegationCycle(tx.PubKey, *tx.Delegate)
+		if err != nil {
+			return tmsp.ErrInternalError.AppendLog(Fmt("Error checking delegates of %X: %v", *tx.Delegate, err))
+		}
+		if cycle {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Delegating to %X would create a cycle", *tx.Delegate))
+		}
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
+
+	state.SetDelegate(tx.PubKey, tx.Delegate)
+
+	acc.Sequence += 1
+
+	return tmsp.OK
+}
+
+func ExecCloseTx(state *State, tx *types.CloseTx, appendTx bool) tmsp.Result {
+	// load account and check it can close the election
+	acc, res := authorize(state, tx.PubKey, types.PermManageElection)
+	if !res.IsOK() {
+		return res
+	}
+
+	if state.GetElection().Closed {
+		return tmsp.ErrUnauthorized.AppendLog("Election is already closed")
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
+
+	if err := state.CloseElection(tx.PubKey); err != nil {
+		return tmsp.ErrInternalError.AppendLog(err.Error())
+	}
+
+	acc.Sequence += 1
+
+	return tmsp.OK
+}
+
+func ExecValidatorTx(state *State, tx *types.ValidatorTx, appendTx bool) tmsp.Result {
+	// load account and check it can change the validators
+	acc, res := authorize(state, tx.PubKey, types.PermManageValidators)
+	if !res.IsOK() {
+		return res
+	}
+
+	// check the changes can be applied before using the nonce
+	vals, err := state.prepareValidators(tx.Validators)
+	if err != nil {
+		return tmsp.ErrUnauthorized.AppendLog(err.Error())
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
+
+	state.applyValidators(vals, tx.Validators)
+
+	acc.Sequence += 1
+
+	return tmsp.OK
+}
+
+func ExecProposeAdminTx(state *State, tx *types.ProposeAdminTx, appendTx bool) tmsp.Result {
+	// load account and check it could make the changes itself
+	acc, res := authorize(state, tx.PubKey, payloadPermissions(state, &tx.Payload))
+	if !res.IsOK() {
+		return res
+	}
+
+	approvals := state.GetElection().ProposalApprovals
+	if approvals == 0 {
+		return tmsp.ErrUnauthorized.AppendLog("Proposals are not allowed")
+	}
+	height := state.GetBlockHeight()
+	if tx.Expires < height {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Proposal expires at height %d, before the current height %d", tx.Expires, height))
+	}
+
+	// check the payload can be applied now, even if it won't be yet
+	changes, res := prepareAdminPayload(state, &tx.Payload, tx.PubKey)
+	if !res.IsOK() {
+		return res
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
+
+	id, err := state.nextProposalID()
 	if err != nil {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting account %X: %v", tx.PubKey, err))
+		return tmsp.ErrInternalError.AppendLog(Fmt("Error getting proposal id: %v", err))
+	}
+	proposal := &types.Proposal{
+		ID:        id,
+		Payload:   &tx.Payload,
+		Proposer:  tx.PubKey,
+		Approvals: []types.PubKey{tx.PubKey},
+		Expires:   tx.Expires,
+	}
+
+	// the proposer may be the only approval needed
+	if len(proposal.Approvals) >= approvals {
+		executeProposal(state, proposal, changes)
+	}
+	state.SetProposal(proposal)
+
+	acc.Sequence += 1
+
+	return tmsp.NewResultOK(nil, Fmt("Proposal %d", id))
+}
+
+func ExecApproveTx(state *State, tx *types.ApproveTx, appendTx bool) tmsp.Result {
+	proposal, err := state.GetProposal(tx.ProposalID)
+	if err != nil {
+		return tmsp.ErrUnauthorized.AppendLog(err.Error())
+	}
+
+	// load account and check it could make the changes itself
+	acc, res := authorize(state, tx.PubKey, payloadPermissions(state, proposal.Payload))
+	if !res.IsOK() {
+		return res
+	}
+	if !proposal.IsPending(state.GetBlockHeight()) {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Proposal %d is executed or expired", proposal.ID))
+	}
+	if proposal.HasApproved(tx.PubKey) {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X already approved proposal %d", tx.PubKey, proposal.ID))
 	}
 
-	// check account is admin type
-	if acc.Type != types.AccountTypeAdmin {
-		return tmsp.ErrUnauthorized.AppendLog(Fmt("Account %X is type %v, not admin (%v)", tx.PubKey, acc.Type, types.AccountTypeAdmin))
+	// if this is the last approval needed, check the payload can be applied
+	var changes *adminChanges
+	if len(proposal.Approvals)+1 >= state.GetElection().ProposalApprovals {
+		var res tmsp.Result
+		changes, res = prepareAdminPayload(state, proposal.Payload, proposal.Proposer)
+		if !res.IsOK() {
+			return res
+		}
 	}
 
 	// check tx.Nonce not already used
@@ -133,7 +530,54 @@ func ExecForkTx(state *State, tx *types.ForkTx, appendTx bool) tmsp.Result {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
 	}
 
+	proposal.Approvals = append(proposal.Approvals, tx.PubKey)
+	if changes != nil {
+		executeProposal(state, proposal, changes)
+	}
+	state.SetProposal(proposal)
+
 	acc.Sequence += 1
 
 	return tmsp.OK
 }
+
+func executeProposal(state *State, proposal *types.Proposal, changes *adminChanges) {
+	applyAdminPayload(state, changes)
+	proposal.Executed = state.GetBlockHeight()
+	state.AddEvent(types.EventProposalExecuted, proposal)
+}
+
+func ExecRotateKeyTx(state *State, tx *types.RotateKeyTx, appendTx bool) tmsp.Result {
+	// the account rotates its own key, or an admin who manages its type replaces a lost one
+	var acc *types.Account
+	var res tmsp.Result
+	if tx.IsSelfSigned() {
+		acc, res = authorize(state, tx.PubKey, 0)
+	} else {
+		old, err := state.GetAccount(tx.OldPubKey)
+		if err != nil {
+			return tmsp.ErrUnauthorized.AppendLog(Fmt("Error getting account %X: %v", tx.OldPubKey, err))
+		}
+		acc, res = authorize(state, tx.PubKey, old.Type.ManagePermission())
+	}
+	if !res.IsOK() {
+		return res
+	}
+
+	rotation, err := state.prepareKeyRotation(tx.OldPubKey, tx.NewPubKey, tx.PubKey)
+	if err != nil {
+		return tmsp.ErrUnauthorized.AppendLog(err.Error())
+	}
+
+	// check tx.Nonce not already used
+	if !state.AddNonce(tx.PubKey, tx.Nonce) {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce %X already used", tx.Nonce))
+	}
+
+	// the account moves with its sequence, so this counts for a self-signed tx too
+	acc.Sequence += 1
+
+	state.applyKeyRotation(rotation)
+
+	return tmsp.OK
+}
@@ -0,0 +1,95 @@
+package state
+
+import (
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// BlockHook runs actions at the start and end of every block,
+// like opening and closing the election on schedule.
+// The height is the state's block height, the same the txs in the block see.
+// A hook must return any error before it changes the state,
+// like the txs do, so a failed hook leaves the block's state as it was
+type BlockHook interface {
+	BeginBlock(s *State, height int) error
+	EndBlock(s *State, height int) error
+}
+
+var blockHooks []BlockHook
+
+// Register a hook to run for every block.
+// Hooks run in the order they are registered,
+// so they must be registered in the same order on every node
+func RegisterBlockHook(hook BlockHook) {
+	blockHooks = append(blockHooks, hook)
+}
+
+func BeginBlock(s *State) error {
+	height := s.GetBlockHeight()
+	for _, hook := range blockHooks {
+		if err := hook.BeginBlock(s, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func EndBlock(s *State) error {
+	height := s.GetBlockHeight()
+	for _, hook := range blockHooks {
+		if err := hook.EndBlock(s, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterBlockHook(scheduleHook{})
+	RegisterBlockHook(nonceHook{})
+}
+
+//----------------------------------------
+// open and close the election at the heights it is scheduled for
+
+type scheduleHook struct{}
+
+func (scheduleHook) BeginBlock(s *State, height int) error {
+	election := s.GetElection()
+	if election.OpenHeight > 0 && height == election.OpenHeight && !election.Closed {
+		s.AddEvent(types.EventElectionOpened, types.EventDataElection{
+			Height:   height,
+			Election: election.Copy(),
+		})
+	}
+	return nil
+}
+
+func (scheduleHook) EndBlock(s *State, height int) error {
+	election := s.GetElection()
+	if election.CloseHeight > 0 && height == election.CloseHeight && !election.Closed {
+		// closed by the schedule, not an admin
+		return s.CloseElection(types.PubKey{})
+	}
+	return nil
+}
+
+//----------------------------------------
+// expire the voters' nonces in the block after the election closes.
+// Not in the same block, so if the app rolls back to the block before the close,
+// the nonces that block needs are still there
+
+type nonceHook struct{}
+
+func (nonceHook) BeginBlock(s *State, height int) error {
+	return nil
+}
+
+func (nonceHook) EndBlock(s *State, height int) error {
+	election := s.GetElection()
+	if election.Closed && election.ClosedHeight == height-1 {
+		// voters can't vote again, so their nonces no longer protect against replays.
+		// they are compacted when the block is saved
+		s.compactNonces = true
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Returns the pubkey of the account with the index
+func (s *State) GetPubKeyByIndex(index int) (types.PubKey, error) {
+	_, pubKeyBytes, exists := s.accounts.tree.Get(types.AccountIndexKeyBytes(index))
+	if !exists || len(pubKeyBytes) == 0 {
+		return types.PubKey{}, fmt.Errorf("Account index %d not found", index)
+	}
+	return types.BytesToPubKey(pubKeyBytes), nil
+}
+
+// Returns the index for a new account, and counts it.
+// Indexes start at 1, so 0 is no index
+func (s *State) nextAccountIndex() (int, error) {
+	var count int
+	_, countBytes, exists := s.accounts.tree.Get(types.AccountCountKeyBytes)
+	if exists && len(countBytes) > 0 {
+		if err := wire.ReadBinaryBytes(countBytes, &count); err != nil {
+			return 0, err
+		}
+	}
+	s.accounts.tree.Set(types.AccountCountKeyBytes, wire.BinaryBytes(count+1))
+	return count + 1, nil
+}
+
+func (s *State) setAccountIndex(index int, pubKey types.PubKey) {
+	s.accounts.tree.Set(types.AccountIndexKeyBytes(index), pubKey.Bytes())
+}
+
+// Set the tx's pubkey from its account index, if it has one
+func (s *State) resolveSigner(tx types.IndexedTx) error {
+	index, pubKey := tx.Signer()
+	if index == 0 {
+		return nil
+	}
+	signer, err := s.GetPubKeyByIndex(index)
+	if err != nil {
+		return err
+	}
+	if pubKey != nil && *pubKey != signer {
+		return fmt.Errorf("Account %d has pubkey %X, not %X", index, signer, *pubKey)
+	}
+	tx.SetSigner(signer)
+	return nil
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// The account is removed from the tree when the state is saved.
+// Its history, nonces and ballots are kept, but its index is not reused
+func (s *State) RemoveAccount(pubKey types.PubKey) {
+	if acc, err := s.GetAccount(pubKey); err == nil {
+		s.accounts.tree.Remove(types.AccountIndexKeyBytes(acc.Index))
+	}
+	s.accounts.RemoveAccount(pubKey)
+}
+
+func (s *State) GetAccountHistory(pubKey types.PubKey) (types.AccountHistory, error) {
+	_, historyBytes, exists := s.accounts.tree.Get(types.AccountHistoryKeyBytes(pubKey))
+	if !exists || len(historyBytes) == 0 {
+		return nil, nil
+	}
+	var history types.AccountHistory
+	err := history.Unmarshal(historyBytes)
+	return history, err
+}
+
+// Returns the accounts with the status in the next block.
+// An empty status returns every account
+func (s *State) GetAccountsByStatus(status types.AccountStatus) ([]*types.PubAccount, error) {
+	accs, err := s.GetAccounts()
+	if err != nil || status == "" {
+		return accs, err
+	}
+	height := s.GetBlockHeight()
+	var filtered []*types.PubAccount
+	for _, acc := range accs {
+		if acc.Status(height) == status {
+			filtered = append(filtered, acc)
+		}
+	}
+	return filtered, nil
+}
+
+type accountChange struct {
+	pubKey  types.PubKey
+	account *types.Account // nil deletes the account
+	history types.AccountHistory
+}
+
+// Check the actions can be applied to the accounts as they are now,
+// and make the changes without applying them.
+// Each account can only be changed once in a payload
+func (s *State) prepareAccountActions(payload *types.AdminPayload, by types.PubKey) ([]accountChange, error) {
+	seen := make(map[string]bool)
+	for _, pubAcc := range payload.PubAccounts {
+		seen[types.AccountKeyString(pubAcc.PubKey)] = true
+	}
+
+	height := s.GetBlockHeight()
+	var changes []accountChange
+	for _, action := range payload.AccountActions {
+		key := types.AccountKeyString(action.PubKey)
+		if seen[key] {
+			return nil, fmt.Errorf("Account %X is changed more than once", action.PubKey)
+		}
+		seen[key] = true
+
+		acc, err := s.GetAccount(action.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		newAcc := new(types.Account)
+		*newAcc = *acc
+		switch action.Action {
+		case types.AccountActionSuspend:
+			if action.Reason == "" {
+				return nil, fmt.Errorf("Suspending account %X needs a reason", action.PubKey)
+			}
+			if action.Until != 0 && action.Until < height {
+				return nil, fmt.Errorf("Suspension of %X ends at height %d, before the current height %d", action.PubKey, action.Until, height)
+			}
+			newAcc.Suspension = &types.Suspension{
+				Reason: action.Reason,
+				Height: height,
+				Until:  action.Until,
+			}
+		case types.AccountActionReinstate:
+			if !acc.IsSuspended(height) {
+				return nil, fmt.Errorf("Account %X is not suspended", action.PubKey)
+			}
+			newAcc.Suspension = nil
+		case types.AccountActionDelete:
+			newAcc = nil
+		default:
+			return nil, fmt.Errorf("Unknown account action %q", action.Action)
+		}
+
+		history, err := s.GetAccountHistory(action.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, types.AccountEvent{
+			Height: height,
+			Action: action.Action,
+			Reason: action.Reason,
+			Until:  action.Until,
+			By:     by,
+		})
+		changes = append(changes, accountChange{action.PubKey, newAcc, history})
+	}
+	return changes, nil
+}
+
+func (s *State) applyAccountActions(changes []accountChange) {
+	for _, c := range changes {
+		if c.account == nil {
+			s.RemoveAccount(c.pubKey)
+		} else {
+			s.SetAccount(c.pubKey, c.account)
+		}
+		s.accounts.tree.Set(types.AccountHistoryKeyBytes(c.pubKey), c.history.Marshal())
+	}
+}
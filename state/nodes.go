@@ -0,0 +1,168 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-wire"
+)
+
+// go-merkle saves each node of the tree under its hash, and a new root
+// shares the nodes that didn't change with the last one.
+// The nodes each height creates and orphans are recorded on Save,
+// so pruning can delete the nodes only pruned heights use
+
+// The nodes of the tree at height-1 that aren't in the tree at height
+func OrphansKey(height int) []byte {
+	return []byte(fmt.Sprintf("ORPHANS/%d", height))
+}
+
+var NodeKeyPrefix = []byte("NODE/")
+
+// When the tree node was saved
+func NodeKey(hash []byte) []byte {
+	return append(append([]byte{}, NodeKeyPrefix...), hash...)
+}
+
+type nodeHeights struct {
+	// the first height of the node's life.
+	// if it's saved again before it's deleted, this is kept,
+	// so the heights of both lives are checked before it's deleted
+	From int
+	// the last height it was saved at
+	Saved int
+}
+
+// a tree node as go-merkle saves it:
+// height, size and key, then a leaf's value or an inner node's children
+type treeNode struct {
+	hash      []byte
+	height    int8
+	leftHash  []byte
+	rightHash []byte
+}
+
+func (s *State) getTreeNode(hash []byte) (*treeNode, error) {
+	buf := s.db.Get(hash)
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("Tree node %X not found", hash)
+	}
+	r, n, err := bytes.NewReader(buf), new(int), new(error)
+	node := &treeNode{hash: hash}
+	node.height = wire.ReadInt8(r, n, err)
+	wire.ReadVarint(r, n, err)       // size
+	wire.ReadByteSlice(r, 0, n, err) // key
+	if node.height > 0 {
+		node.leftHash = wire.ReadByteSlice(r, 0, n, err)
+		node.rightHash = wire.ReadByteSlice(r, 0, n, err)
+	}
+	if *err != nil {
+		return nil, fmt.Errorf("Error decoding tree node %X: %v", hash, *err)
+	}
+	return node, nil
+}
+
+// Returns the nodes only in the old tree and the nodes only in the new tree.
+// A node is in a tree at most once, so a node in both trees is reached
+// in both at its height, and its subtree is skipped.
+// Only the nodes that changed are read
+func (s *State) diffTrees(oldRoot, newRoot []byte) (orphaned, created [][]byte, err error) {
+	if bytes.Equal(oldRoot, newRoot) {
+		return nil, nil, nil
+	}
+	// the nodes to visit in each tree, by height
+	var trees [2]map[int8]map[string]*treeNode
+	maxHeight := int8(-1)
+	push := func(tree int, hash []byte) error {
+		if len(hash) == 0 {
+			return nil
+		}
+		node, err := s.getTreeNode(hash)
+		if err != nil {
+			return err
+		}
+		if trees[tree][node.height] == nil {
+			trees[tree][node.height] = make(map[string]*treeNode)
+		}
+		trees[tree][node.height][string(hash)] = node
+		if node.height > maxHeight {
+			maxHeight = node.height
+		}
+		return nil
+	}
+	for i, root := range [][]byte{oldRoot, newRoot} {
+		trees[i] = make(map[int8]map[string]*treeNode)
+		if err := push(i, root); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// children are lower than their parents
+	for h := maxHeight; h >= 0; h-- {
+		for i := range trees {
+			other := trees[1-i][h]
+			for key, node := range trees[i][h] {
+				if _, shared := other[key]; shared {
+					continue
+				}
+				if i == 0 {
+					orphaned = append(orphaned, node.hash)
+				} else {
+					created = append(created, node.hash)
+				}
+				if err := push(i, node.leftHash); err != nil {
+					return nil, nil, err
+				}
+				if err := push(i, node.rightHash); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	return orphaned, created, nil
+}
+
+// Record the nodes created and orphaned by the tree saved at the state's height
+func (s *State) saveTreeNodes(batch dbm.Batch, prevRoot, root []byte) error {
+	orphaned, created, err := s.diffTrees(prevRoot, root)
+	if err != nil {
+		return err
+	}
+	for _, hash := range created {
+		heights := nodeHeights{s.height, s.height}
+		if old, err := s.getNodeHeights(hash); err != nil {
+			return err
+		} else if old != nil {
+			heights.From = old.From
+		}
+		batch.Set(NodeKey(hash), wire.BinaryBytes(heights))
+	}
+	if len(orphaned) > 0 {
+		batch.Set(OrphansKey(s.height), wire.BinaryBytes(orphaned))
+	}
+	return nil
+}
+
+// Returns nil if the node wasn't recorded
+func (s *State) getNodeHeights(hash []byte) (*nodeHeights, error) {
+	heightsBytes := s.db.Get(NodeKey(hash))
+	if len(heightsBytes) == 0 {
+		return nil, nil
+	}
+	heights := new(nodeHeights)
+	if err := wire.ReadBinaryBytes(heightsBytes, heights); err != nil {
+		return nil, err
+	}
+	return heights, nil
+}
+
+func (s *State) getOrphans(height int) ([][]byte, error) {
+	orphansBytes := s.db.Get(OrphansKey(height))
+	if len(orphansBytes) == 0 {
+		return nil, nil
+	}
+	var orphans [][]byte
+	err := wire.ReadBinaryBytes(orphansBytes, &orphans)
+	return orphans, err
+}
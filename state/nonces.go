@@ -1,7 +1,11 @@
 package state
 
 import (
+	"fmt"
+	"sort"
+
 	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-wire"
 	"github.com/tendermint/lil-voterin/types"
 )
 
@@ -9,16 +13,53 @@ func NonceKey(pubKey types.PubKey, nonce []byte) string {
 	return string(pubKey.Bytes()) + string(nonce)
 }
 
+// The nonces used by each pubkey are listed one per key,
+// so they can be found again to compact them.
+// The index key has the number listed.
+// Every pubkey that has used a nonce is listed too,
+// so a snapshot has the nonces of deleted and rotated keys
+var (
+	NonceIndexKeyPrefix = []byte("NONCES/")
+	NonceListKeyPrefix  = []byte("NONCELIST/")
+
+	NonceSignersKey      = []byte("NONCESIGNERS")
+	NonceSignerKeyPrefix = []byte("NONCESIGNER/")
+)
+
+func NonceIndexKey(pubKey types.PubKey) []byte {
+	return append(append([]byte{}, NonceIndexKeyPrefix...), pubKey.Bytes()...)
+}
+
+func NonceListKey(pubKey types.PubKey, i int) []byte {
+	return []byte(fmt.Sprintf("%s%s/%d", NonceListKeyPrefix, pubKey.Bytes(), i))
+}
+
+func NonceSignerKey(i int) []byte {
+	return []byte(fmt.Sprintf("%s%d", NonceSignerKeyPrefix, i))
+}
+
+// A nonce in the list, with the height it was saved at,
+// so a snapshot of an earlier height can leave it out
+type savedNonce struct {
+	Nonce  []byte `json:"nonce"`
+	Height int    `json:"height"`
+}
+
+type usedNonce struct {
+	pubKey types.PubKey
+	nonce  []byte
+}
+
 // Cached nonces backed by db
 // Suitable for blocks or mempool
 type Nonces struct {
-	cache map[string]struct{}
+	cache map[string]usedNonce
 	db    dbm.DB
 }
 
 func NewNonces(db dbm.DB) *Nonces {
 	return &Nonces{
-		cache: make(map[string]struct{}),
+		cache: make(map[string]usedNonce),
 		db:    db,
 	}
 }
@@ -42,16 +83,140 @@ func (n *Nonces) AddNonce(pubKey types.PubKey, nonce []byte) bool {
 		return false
 	}
 	// cache nonce
-	n.cache[nonceKey] = struct{}{}
+	n.cache[nonceKey] = usedNonce{pubKey, nonce}
 	return true
 }
 
-func (n *Nonces) Save() {
+// Write the cached nonces to the batch,
+// appending them to each pubkey's list with the height
+func (n *Nonces) Save(batch dbm.Batch, height int) error {
 	b := []byte{1}
+	keys := []string{}
 	for nonceKey, _ := range n.cache {
-		n.db.Set([]byte(nonceKey), b)
+		keys = append(keys, nonceKey)
+	}
+	sort.Strings(keys)
+
+	listed, err := n.getCount(NonceSignersKey)
+	if err != nil {
+		return err
+	}
+	signers := listed
+	counts := make(map[string]int)
+	for _, nonceKey := range keys {
+		batch.Set([]byte(nonceKey), b)
+		used := n.cache[nonceKey]
+		indexKey := string(NonceIndexKey(used.pubKey))
+		count, ok := counts[indexKey]
+		if !ok {
+			// the index is kept when the nonces are compacted,
+			// so a signer is only listed once
+			if len(n.db.Get([]byte(indexKey))) == 0 {
+				batch.Set(NonceSignerKey(signers), used.pubKey.Bytes())
+				signers += 1
+			}
+			if count, err = n.getCount([]byte(indexKey)); err != nil {
+				return err
+			}
+		}
+		batch.Set(NonceListKey(used.pubKey, count), wire.BinaryBytes(savedNonce{used.nonce, height}))
+		counts[indexKey] = count + 1
+	}
+	for indexKey, count := range counts {
+		batch.Set([]byte(indexKey), wire.BinaryBytes(count))
+	}
+	if signers > listed {
+		batch.Set(NonceSignersKey, wire.BinaryBytes(signers))
 	}
 
 	// clear the cache
-	n.cache = make(map[string]struct{})
+	n.cache = make(map[string]usedNonce)
+	return nil
+}
+
+// Delete all the nonces used by the pubkey, including those not saved yet.
+// Txs signed by it can be replayed after, so this is only safe
+// once every tx type it may send would be rejected anyway
+func (n *Nonces) Compact(batch dbm.Batch, pubKey types.PubKey) error {
+	nonces, err := n.getNonces(pubKey)
+	if err != nil {
+		return err
+	}
+	for i, saved := range nonces {
+		batch.Delete([]byte(NonceKey(pubKey, saved.Nonce)))
+		batch.Delete(NonceListKey(pubKey, i))
+	}
+	batch.Set(NonceIndexKey(pubKey), wire.BinaryBytes(0))
+	for nonceKey, used := range n.cache {
+		if used.pubKey == pubKey {
+			delete(n.cache, nonceKey)
+		}
+	}
+	return nil
+}
+
+// Returns the saved nonces used by the pubkey, in the order they were saved
+func (n *Nonces) getNonces(pubKey types.PubKey) ([]savedNonce, error) {
+	count, err := n.getCount(NonceIndexKey(pubKey))
+	if err != nil {
+		return nil, err
+	}
+	nonces := make([]savedNonce, count)
+	for i := range nonces {
+		if err := wire.ReadBinaryBytes(n.db.Get(NonceListKey(pubKey, i)), &nonces[i]); err != nil {
+			return nil, fmt.Errorf("Error reading nonce %d of %X: %v", i, pubKey, err)
+		}
+	}
+	return nonces, nil
+}
+
+// Returns every pubkey that has used a nonce, in the order they were first saved
+func (n *Nonces) getSigners() ([]types.PubKey, error) {
+	count, err := n.getCount(NonceSignersKey)
+	if err != nil {
+		return nil, err
+	}
+	signers := make([]types.PubKey, count)
+	for i := range signers {
+		if signers[i], err = types.PubKeyFromBytes(n.db.Get(NonceSignerKey(i))); err != nil {
+			return nil, fmt.Errorf("Error reading nonce signer %d: %v", i, err)
+		}
+	}
+	return signers, nil
+}
+
+func (n *Nonces) getCount(indexKey []byte) (int, error) {
+	var count int
+	if b := n.db.Get(indexKey); len(b) > 0 {
+		if err := wire.ReadBinaryBytes(b, &count); err != nil {
+			return 0, fmt.Errorf("Error reading nonce index %X: %v", indexKey, err)
+		}
+	}
+	return count, nil
+}
+
+// Delete the nonces saved after the height, so the txs of the later blocks
+// can be executed again. Nonces are listed in the order they were saved
+func (n *Nonces) Rollback(batch dbm.Batch, height int) error {
+	signers, err := n.getSigners()
+	if err != nil {
+		return err
+	}
+	for _, pubKey := range signers {
+		nonces, err := n.getNonces(pubKey)
+		if err != nil {
+			return err
+		}
+		count := len(nonces)
+		for count > 0 && nonces[count-1].Height > height {
+			count -= 1
+			batch.Delete([]byte(NonceKey(pubKey, nonces[count].Nonce)))
+			batch.Delete(NonceListKey(pubKey, count))
+		}
+		if count < len(nonces) {
+			batch.Set(NonceIndexKey(pubKey), wire.BinaryBytes(count))
+		}
+	}
+	n.cache = make(map[string]usedNonce)
+	return nil
 }
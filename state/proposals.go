@@ -0,0 +1,66 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/lil-voterin/types"
+)
+
+func (s *State) GetProposal(id int) (*types.Proposal, error) {
+	_, proposalBytes, exists := s.accounts.tree.Get(types.ProposalKeyBytes(id))
+	if !exists || len(proposalBytes) == 0 {
+		return nil, fmt.Errorf("Proposal %d not found", id)
+	}
+	proposal := new(types.Proposal)
+	err := proposal.Unmarshal(proposalBytes)
+	return proposal, err
+}
+
+func (s *State) SetProposal(proposal *types.Proposal) {
+	s.accounts.tree.Set(types.ProposalKeyBytes(proposal.ID), proposal.Marshal())
+}
+
+// Returns the id for a new proposal, and counts it
+func (s *State) nextProposalID() (int, error) {
+	var count int
+	_, countBytes, exists := s.accounts.tree.Get(types.ProposalCountKeyBytes)
+	if exists && len(countBytes) > 0 {
+		if err := wire.ReadBinaryBytes(countBytes, &count); err != nil {
+			return 0, err
+		}
+	}
+	s.accounts.tree.Set(types.ProposalCountKeyBytes, wire.BinaryBytes(count+1))
+	return count + 1, nil
+}
+
+// Returns every proposal, ordered by id
+func (s *State) GetProposals() ([]*types.Proposal, error) {
+	var proposals []*types.Proposal
+	var iterErr error
+	stopped := s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if !bytes.HasPrefix(key, types.ProposalKeyPrefix) {
+			return false
+		}
+		proposal := new(types.Proposal)
+		if err := proposal.Unmarshal(value); err != nil {
+			iterErr = err
+			return true
+		}
+		proposals = append(proposals, proposal)
+		return false
+	})
+	if stopped {
+		return nil, iterErr
+	}
+	sort.Sort(proposalsByID(proposals))
+	return proposals, nil
+}
+
+type proposalsByID []*types.Proposal
+
+func (ps proposalsByID) Len() int           { return len(ps) }
+func (ps proposalsByID) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+func (ps proposalsByID) Less(i, j int) bool { return ps[i].ID < ps[j].ID }
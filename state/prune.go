@@ -0,0 +1,142 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/go-wire"
+)
+
+var (
+	// height of the last state the pruning policy has decided on
+	PrunedKey = []byte("PRUNED")
+
+	// the heights it decided to keep
+	KeptKey = []byte("KEPT")
+)
+
+// PruningPolicy decides which of the saved heights can still be loaded.
+// The zero value keeps everything.
+// Pruning forgets the root hash saved for a height, so it can't be queried,
+// and deletes the tree nodes that only pruned heights used.
+// It is local to the node and does not affect the app hash.
+// The height before the latest is always kept, so the app can roll back to it
+type PruningPolicy struct {
+	KeepRecent int // keep the last N heights. 0 keeps all, unless KeepEvery is set
+	KeepEvery  int // keep every Kth height, in addition to the recent ones
+}
+
+func (p PruningPolicy) Validate() error {
+	if p.KeepRecent < 0 {
+		return fmt.Errorf("KeepRecent cannot be negative")
+	}
+	if p.KeepEvery < 0 {
+		return fmt.Errorf("KeepEvery cannot be negative")
+	}
+	return nil
+}
+
+// Returns true if the state saved at height should be kept
+// once the state at latest is committed
+func (p PruningPolicy) Keep(height, latest int) bool {
+	if latest-height < minKeepRecent {
+		return true
+	}
+	if p.KeepEvery > 0 && height%p.KeepEvery == 0 {
+		return true
+	}
+	if p.KeepRecent == 0 {
+		// keep everything, unless only every Kth height is kept
+		return p.KeepEvery == 0
+	}
+	return latest-height < p.KeepRecent
+}
+
+// Heights more than this far behind the latest won't be kept for being recent,
+// so the policy can make its final decision on them
+func (p PruningPolicy) window() int {
+	if p.KeepRecent > minKeepRecent {
+		return p.KeepRecent
+	}
+	return minKeepRecent
+}
+
+// the latest height and the one before it, for Rollback
+const minKeepRecent = 2
+
+// Forget the saved heights that the policy no longer keeps,
+// and delete the tree nodes no kept height uses.
+// Heights are only decided once, so the last one decided is saved
+// and a stricter policy after a restart only applies from there
+func (s *State) Prune(policy PruningPolicy) error {
+	if policy.KeepRecent == 0 && policy.KeepEvery == 0 {
+		return nil
+	}
+
+	pruned := -1
+	if prunedBytes := s.db.Get(PrunedKey); len(prunedBytes) > 0 {
+		if err := wire.ReadBinaryBytes(prunedBytes, &pruned); err != nil {
+			return err
+		}
+	}
+	var kept []int
+	if keptBytes := s.db.Get(KeptKey); len(keptBytes) > 0 {
+		if err := wire.ReadBinaryBytes(keptBytes, &kept); err != nil {
+			return err
+		}
+	}
+
+	last := s.height - policy.window()
+	if last <= pruned {
+		return nil
+	}
+	batch := s.db.NewBatch()
+	for h := pruned + 1; h <= last; h++ {
+		if policy.Keep(h, s.height) {
+			kept = append(kept, h)
+		} else {
+			batch.Delete(RootKey(h))
+		}
+	}
+
+	// the nodes orphaned at height were in the trees from when they were saved to height-1.
+	// once height-1 is decided, they're deleted if none of those heights is kept
+	for height := pruned + 2; height <= last+1; height++ {
+		orphans, err := s.getOrphans(height)
+		if err != nil {
+			return err
+		}
+		for _, hash := range orphans {
+			heights, err := s.getNodeHeights(hash)
+			if err != nil {
+				return err
+			}
+			from := 0 // saved before nodes were recorded
+			if heights != nil {
+				if heights.Saved >= height {
+					// saved again, so a later height orphans it
+					continue
+				}
+				from = heights.From
+			}
+			if isKept(kept, from, height-1) {
+				continue
+			}
+			batch.Delete(hash)
+			batch.Delete(NodeKey(hash))
+		}
+		batch.Delete(OrphansKey(height))
+	}
+
+	batch.Set(KeptKey, wire.BinaryBytes(kept))
+	batch.Set(PrunedKey, wire.BinaryBytes(last))
+	batch.Write()
+	return nil
+}
+
+// Returns true if a height from first to last is kept.
+// kept is sorted
+func isKept(kept []int, first, last int) bool {
+	i := sort.SearchInts(kept, first)
+	return i < len(kept) && kept[i] <= last
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tendermint/go-merkle"
+	"github.com/tendermint/go-wire"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Tendermint saves its own state after the app commits a block,
+// so after a crash in between it executes the block again,
+// with the app hash from before it in the header.
+// The app can't be behind tendermint, only one block ahead.
+
+// Roll the state back to the height before the latest,
+// if appHash is the root saved for it, and load it.
+// The nonces saved since are deleted and the tree's root is set back.
+// The tree nodes of the latest height are left for its block to save again
+func (s *State) Rollback(appHash []byte) error {
+	latest := s.height
+	height := latest - 1
+	root := s.db.Get(RootKey(height))
+	if len(root) == 0 {
+		return fmt.Errorf("No state saved at height %d to roll back to", height)
+	}
+	if !bytes.Equal(root, appHash) {
+		return fmt.Errorf("App hash %X is not the root hash %X saved at height %d", appHash, root, height)
+	}
+
+	// voters' nonces are compacted in the block after the election closes,
+	// so their txs are rejected without them when that block is executed again
+	compacted := -1
+	if compactedBytes := s.db.Get(CompactedKey); len(compactedBytes) > 0 {
+		if err := wire.ReadBinaryBytes(compactedBytes, &compacted); err != nil {
+			return err
+		}
+	}
+	if compacted == latest {
+		prev, err := s.LoadHeight(height)
+		if err != nil {
+			return err
+		}
+		if !prev.election.Closed {
+			return fmt.Errorf("Nonces were compacted at height %d before the election closed, so it can't be rolled back", latest)
+		}
+	}
+
+	batch := s.db.NewBatch()
+	if err := s.nonces.Rollback(batch, height); err != nil {
+		return err
+	}
+	if compacted == latest {
+		// the block compacts them again
+		batch.Delete(CompactedKey)
+	}
+	batch.Delete(OrphansKey(latest))
+	batch.Delete(RootKey(latest))
+	batch.Set(HeightKey, wire.BinaryBytes(height))
+	batch.Set(StateKey, root)
+	batch.Write()
+
+	// drop everything cached from the latest height
+	s.election = types.NewElection()
+	s.tally = types.NewTally(0)
+	s.accounts = NewAccounts(merkle.NewIAVLTree(100, s.db))
+	s.nonces = NewNonces(s.db)
+	s.compactNonces = false
+	s.events = nil
+	s.validatorDiffs = nil
+	return s.Load()
+}
@@ -0,0 +1,152 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Returns the key an account was rotated to, if any
+func (s *State) GetRotatedKey(pubKey types.PubKey) *types.PubKey {
+	_, newKeyBytes, exists := s.accounts.tree.Get(types.RotatedKeyBytes(pubKey))
+	if !exists || len(newKeyBytes) == 0 {
+		return nil
+	}
+	newKey := types.BytesToPubKey(newKeyBytes)
+	return &newKey
+}
+
+// Returns an error if the key has ever been used.
+// Keys of deleted accounts keep their history and ballots, so they aren't fresh either
+func (s *State) checkFreshKey(pubKey types.PubKey) error {
+	if _, err := s.GetAccount(pubKey); err == nil {
+		return fmt.Errorf("Account %X already exists", pubKey)
+	}
+	for _, key := range [][]byte{
+		types.RotatedKeyBytes(pubKey),
+		types.AccountHistoryKeyBytes(pubKey),
+		types.BallotsKeyBytes(pubKey),
+		types.BallotCountKeyBytes(pubKey),
+		types.CountedBallotsKeyBytes(pubKey),
+		types.DelegateKeyBytes(pubKey),
+	} {
+		if s.accounts.tree.Has(key) {
+			return fmt.Errorf("Key %X has already been used", pubKey)
+		}
+	}
+	return nil
+}
+
+type keyRotation struct {
+	oldKey, newKey types.PubKey
+	account        *types.Account
+	history        types.AccountHistory
+	delegators     []types.PubKey    // who delegated to the old key
+	proposals      []*types.Proposal // pending proposals made or approved by the old key
+	election       *types.Election   // if the old key is on the eligibility list
+	eligibility    []eligibilityChange
+}
+
+// Check the account can be moved to the new key and collect everything to move
+func (s *State) prepareKeyRotation(oldKey, newKey, by types.PubKey) (*keyRotation, error) {
+	acc, err := s.GetAccount(oldKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkFreshKey(newKey); err != nil {
+		return nil, err
+	}
+
+	r := &keyRotation{oldKey: oldKey, newKey: newKey, account: acc}
+	if r.history, err = s.GetAccountHistory(oldKey); err != nil {
+		return nil, err
+	}
+	r.history = append(r.history, types.AccountEvent{
+		Height: s.GetBlockHeight(),
+		Action: types.AccountActionRotate,
+		By:     by,
+	})
+
+	oldKeyBytes := oldKey.Bytes()
+	s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if bytes.HasPrefix(key, types.DelegateKeyPrefix) && bytes.Equal(value, oldKeyBytes) {
+			r.delegators = append(r.delegators, types.BytesToPubKey(key[len(types.DelegateKeyPrefix):]))
+		}
+		return false
+	})
+
+	if s.HasEligibleVoter(oldKey) {
+		actions := []types.EligibilityAction{{Remove: true, Voters: []types.PubKey{oldKey}}}
+		if !s.HasEligibleVoter(newKey) {
+			actions = append(actions, types.EligibilityAction{Voters: []types.PubKey{newKey}})
+		}
+		r.election = s.GetElection().Copy()
+		if r.eligibility, err = s.prepareEligibility(r.election, actions); err != nil {
+			return nil, err
+		}
+	}
+
+	proposals, err := s.GetProposals()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range proposals {
+		if p.IsPending(s.GetBlockHeight()) && (p.Proposer == oldKey || p.HasApproved(oldKey)) {
+			r.proposals = append(r.proposals, p)
+		}
+	}
+	return r, nil
+}
+
+// Move the account, its ballots, ballot count, delegation and history to the new key,
+// point delegations, pending proposals and the eligibility list at the new key, and remember the rotation
+// so the old key can't be added again
+func (s *State) applyKeyRotation(r *keyRotation) {
+	// the account keeps its index
+	s.RemoveAccount(r.oldKey)
+	s.accounts.SetAccount(r.newKey, r.account)
+	s.setAccountIndex(r.account.Index, r.newKey)
+
+	for _, keyBytes := range []func(types.PubKey) []byte{
+		types.BallotsKeyBytes,
+		types.BallotCountKeyBytes,
+		types.CountedBallotsKeyBytes,
+		types.DelegateKeyBytes,
+	} {
+		if _, value, exists := s.accounts.tree.Get(keyBytes(r.oldKey)); exists {
+			s.accounts.tree.Set(keyBytes(r.newKey), value)
+			s.accounts.tree.Remove(keyBytes(r.oldKey))
+		}
+	}
+
+	for _, delegator := range r.delegators {
+		s.SetDelegate(delegator, &r.newKey)
+	}
+
+	if r.election != nil {
+		s.applyEligibility(r.eligibility)
+		s.SetElection(r.election)
+	}
+
+	for _, p := range r.proposals {
+		if p.Proposer == r.oldKey {
+			p.Proposer = r.newKey
+		}
+		for i, approval := range p.Approvals {
+			if approval == r.oldKey {
+				p.Approvals[i] = r.newKey
+			}
+		}
+		s.SetProposal(p)
+	}
+
+	// both keys record the rotation, pointing at the other
+	oldHistory := append(types.AccountHistory{}, r.history...)
+	oldHistory[len(oldHistory)-1].Key = &r.newKey
+	s.accounts.tree.Set(types.AccountHistoryKeyBytes(r.oldKey), oldHistory.Marshal())
+	r.history[len(r.history)-1].Key = &r.oldKey
+	s.accounts.tree.Set(types.AccountHistoryKeyBytes(r.newKey), r.history.Marshal())
+
+	s.accounts.tree.Set(types.RotatedKeyBytes(r.oldKey), r.newKey.Bytes())
+}
@@ -0,0 +1,169 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	dbm "github.com/tendermint/go-db"
+	"github.com/tendermint/go-wire"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Snapshot is everything needed to rebuild the state,
+// with the election, tally, outcome and accounts decoded for auditors.
+// Entries has the remaining keys in the merkle tree:
+// ballots, ballot counts, delegates and write-ins
+type Snapshot struct {
+	Height   int                 `json:"height"`
+	AppHash  []byte              `json:"app_hash"`
+	Election *types.Election     `json:"election"`
+	Tally    *types.Tally        `json:"tally"`
+	Outcome  *types.Outcome      `json:"outcome,omitempty"`
+	Accounts []*types.PubAccount `json:"accounts"`
+	Entries  []SnapshotEntry     `json:"entries"`
+	Nonces   []SnapshotNonces    `json:"nonces"`
+}
+
+type SnapshotEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type SnapshotNonces struct {
+	PubKey types.PubKey `json:"pub_key"`
+	Nonces [][]byte     `json:"nonces"`
+}
+
+// Export the state. The tree is iterated in key order, and only the nonces
+// saved by the state's height are included, so the snapshot is canonical.
+// Heights before the last nonce compaction can't be exported,
+// since the nonces they need are gone
+func (s *State) Export() (*Snapshot, error) {
+	if compactedBytes := s.db.Get(CompactedKey); len(compactedBytes) > 0 {
+		var compacted int
+		if err := wire.ReadBinaryBytes(compactedBytes, &compacted); err != nil {
+			return nil, err
+		}
+		if s.height < compacted {
+			return nil, fmt.Errorf("Nonces were compacted at height %d, so height %d can't be exported", compacted, s.height)
+		}
+	}
+
+	accs, err := s.GetAccounts()
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := s.GetOutcome()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		Height:   s.height,
+		AppHash:  s.accounts.tree.Hash(),
+		Election: s.election,
+		Tally:    s.tally,
+		Outcome:  outcome,
+		Accounts: accs,
+	}
+
+	s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		// the accounts and the decoded values are already in the snapshot
+		if types.IsAccountKey(key) ||
+			bytes.Equal(key, types.ElectionKeyBytes) ||
+			bytes.Equal(key, types.TallyKeyBytes) ||
+			bytes.Equal(key, types.OutcomeKeyBytes) {
+			return false
+		}
+		snap.Entries = append(snap.Entries, SnapshotEntry{key, value})
+		return false
+	})
+
+	// every key's nonces, so txs by keys deleted or rotated can't be replayed
+	signers, err := s.nonces.getSigners()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(pubKeysByBytes(signers))
+	for _, pubKey := range signers {
+		saved, err := s.nonces.getNonces(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		var nonces [][]byte
+		for _, n := range saved {
+			if n.Height <= s.height {
+				nonces = append(nonces, n.Nonce)
+			}
+		}
+		if len(nonces) > 0 {
+			snap.Nonces = append(snap.Nonces, SnapshotNonces{pubKey, nonces})
+		}
+	}
+	return snap, nil
+}
+
+type pubKeysByBytes []types.PubKey
+
+func (ps pubKeysByBytes) Len() int      { return len(ps) }
+func (ps pubKeysByBytes) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
+func (ps pubKeysByBytes) Less(i, j int) bool {
+	return bytes.Compare(ps[i].Bytes(), ps[j].Bytes()) < 0
+}
+
+// Build the state from a snapshot and save it to the db as the genesis state,
+// at the snapshot's height.
+// Returns an error if the app hash doesn't match the snapshot's,
+// in which case nothing is saved
+func ImportSnapshot(db dbm.DB, snap *Snapshot) (*State, error) {
+	s := NewState(db)
+	if s.IsCommitted() {
+		return nil, fmt.Errorf("DB already has a state")
+	}
+	if snap.Election == nil || snap.Tally == nil {
+		return nil, fmt.Errorf("Snapshot must have an election and a tally")
+	}
+	if snap.Height < 0 {
+		return nil, fmt.Errorf("Snapshot height cannot be negative")
+	}
+	s.SetHeight(snap.Height)
+
+	s.SetTally(snap.Tally)
+	s.SetElection(snap.Election)
+	for _, acc := range snap.Accounts {
+		// the indexes are in the entries
+		s.accounts.SetAccount(acc.PubKey, acc.Account)
+	}
+	for _, entry := range snap.Entries {
+		s.accounts.tree.Set(entry.Key, entry.Value)
+	}
+	if snap.Outcome != nil {
+		s.accounts.tree.Set(types.OutcomeKeyBytes, snap.Outcome.Marshal())
+	}
+	for _, n := range snap.Nonces {
+		for _, nonce := range n.Nonces {
+			if !s.AddNonce(n.PubKey, nonce) {
+				return nil, fmt.Errorf("Duplicate nonce %X for %X", nonce, n.PubKey)
+			}
+		}
+	}
+
+	// check the hash before anything is written to the db
+	s.accounts.tree.Set(types.ElectionKeyBytes, s.election.Marshal())
+	s.accounts.tree.Set(types.TallyKeyBytes, s.tally.Marshal())
+	s.accounts.Sync()
+	if hash := s.accounts.tree.Hash(); !bytes.Equal(hash, snap.AppHash) {
+		return nil, fmt.Errorf("App hash %X does not match the snapshot's %X", hash, snap.AppHash)
+	}
+
+	if _, err := s.Save(); err != nil {
+		return nil, err
+	}
+	// the height's root must be the one saved
+	if root := db.Get(RootKey(snap.Height)); !bytes.Equal(root, snap.AppHash) {
+		return nil, fmt.Errorf("Saved root %X at height %d does not match the snapshot's app hash %X", root, snap.Height, snap.AppHash)
+	}
+	return s, nil
+}
@@ -1,15 +1,31 @@
 package state
 
 import (
+	"bytes"
 	"fmt"
 
 	dbm "github.com/tendermint/go-db"
 	"github.com/tendermint/go-merkle"
+	"github.com/tendermint/go-wire"
 
 	"github.com/tendermint/lil-voterin/types"
 )
 
-var StateKey = []byte("STATE")
+var (
+	StateKey   = []byte("STATE")   // root hash of the latest state
+	HeightKey  = []byte("HEIGHT")  // height of the latest state
+	PendingKey = []byte("PENDING") // height of a commit in progress
+
+	// height of the last nonce compaction.
+	// snapshots of earlier heights would be missing the nonces
+	CompactedKey = []byte("COMPACTED")
+)
+
+// Root hash of the state saved at each height,
+// so old versions of the tree can be loaded
+func RootKey(height int) []byte {
+	return []byte(fmt.Sprintf("ROOT/%d", height))
+}
 
 // State manages accounts, their nonces, and the tally.
 // It is suitable for blocks and mempool
@@ -18,17 +34,32 @@ var StateKey = []byte("STATE")
 // Not thread-safe
 type State struct {
 	chainID string
+	height  int    // of the last block committed
+	appHash []byte // root hash of the state at height
 
+	election *types.Election
 	tally    *types.Tally
 	accounts *Accounts
 	nonces   *Nonces
 
+	// set by the nonce hook after the election closes, so the voters' nonces are compacted on Save
+	compactNonces bool
+
+	// fired when the block is committed
+	events []types.Event
+
+	// returned to tendermint at the end of the block
+	validatorDiffs []types.Validator
+
 	db dbm.DB
 }
 
 func (s *State) Copy() *State {
 	return &State{
 		chainID:  s.chainID,
+		height:   s.height,
+		appHash:  s.appHash,
+		election: s.election.Copy(),
 		tally:    s.tally.Copy(),
 		accounts: s.accounts.Copy(),
 		nonces:   s.nonces.Copy(),
@@ -36,10 +67,11 @@ func (s *State) Copy() *State {
 	}
 }
 
-func NewState(db dbm.DB, nCandidates int) *State {
+func NewState(db dbm.DB) *State {
 	return &State{
 		chainID:  "", // TODO
-		tally:    types.NewTally(nCandidates),
+		election: types.NewElection(),
+		tally:    types.NewTally(0),
 		accounts: NewAccounts(merkle.NewIAVLTree(100, db)),
 		nonces:   NewNonces(db),
 		db:       db,
@@ -50,6 +82,34 @@ func (s *State) GetChainID() string {
 	return s.chainID
 }
 
+func (s *State) GetHeight() int {
+	return s.height
+}
+
+func (s *State) SetHeight(height int) {
+	s.height = height
+}
+
+// Height of the block being executed on the state
+func (s *State) GetBlockHeight() int {
+	return s.height + 1
+}
+
+// Returns the root hash of the state last saved or loaded
+func (s *State) GetAppHash() []byte {
+	return s.appHash
+}
+
+func (s *State) GetElection() *types.Election {
+	return s.election
+}
+
+func (s *State) SetElection(election *types.Election) {
+	s.election = election
+	// the tally has a count for every registered candidate
+	s.tally.Grow(len(election.Candidates))
+}
+
 func (s *State) GetTally() *types.Tally {
 	return s.tally
 }
@@ -62,90 +122,272 @@ func (s *State) GetAccount(pubKey types.PubKey) (*types.Account, error) {
 	return s.accounts.GetAccount(pubKey)
 }
 
+// New accounts are given the next index. Changed accounts keep theirs
 func (s *State) SetAccount(pubKey types.PubKey, account *types.Account) error {
-	return s.accounts.SetAccount(pubKey, account)
+	acc := *account
+	if old, err := s.GetAccount(pubKey); err == nil {
+		acc.Index = old.Index
+	} else {
+		index, err := s.nextAccountIndex()
+		if err != nil {
+			return err
+		}
+		acc.Index = index
+		s.setAccountIndex(index, pubKey)
+	}
+	return s.accounts.SetAccount(pubKey, &acc)
+}
+
+// Add an event to fire when the block is committed
+func (s *State) AddEvent(name string, data types.EventData) {
+	s.events = append(s.events, types.Event{name, data})
+}
+
+// Return the events added since the last call
+func (s *State) PopEvents() []types.Event {
+	events := s.events
+	s.events = nil
+	return events
 }
 
 func (s *State) AddNonce(pubKey types.PubKey, nonce []byte) bool {
 	return s.nonces.AddNonce(pubKey, nonce)
 }
 
-// Sync the state caches to their dbs and save the merkleized state
+// Sync the state caches to their dbs and save the merkleized state.
+// The tree saves its own nodes, but they aren't used until StateKey points at the new root,
+// so everything else is written in one batch and a crash leaves the db at the last height
 func (s *State) Save() ([]byte, error) {
-	// sync nonces to disk
-	s.nonces.Save()
+	// mark the commit as started, so a crash before the batch is written can be reported
+	s.db.SetSync(PendingKey, wire.BinaryBytes(s.height))
 
 	// write the merkle tree updates to disk
+	prevRoot := s.appHash
 	rootHash := s.saveAccountsAndTally()
 
-	// save  the rootHash
-	s.db.Set(StateKey, rootHash)
+	batch := s.db.NewBatch()
+
+	// so pruning can delete the nodes of old trees
+	if err := s.saveTreeNodes(batch, prevRoot, rootHash); err != nil {
+		return nil, err
+	}
+
+	// compact before saving the nonces, so those from this block are compacted too
+	if s.compactNonces {
+		if err := s.compactVoterNonces(batch); err != nil {
+			return nil, err
+		}
+		batch.Set(CompactedKey, wire.BinaryBytes(s.height))
+		s.compactNonces = false
+	}
+	if err := s.nonces.Save(batch, s.height); err != nil {
+		return nil, err
+	}
+
+	// save the rootHash, and index it by height
+	batch.Set(RootKey(s.height), rootHash)
+	batch.Set(HeightKey, wire.BinaryBytes(s.height))
+	batch.Set(StateKey, rootHash)
+	batch.Delete(PendingKey)
+	batch.Write()
 
+	s.appHash = rootHash
 	return rootHash, nil
 }
 
 func (s *State) saveAccountsAndTally() []byte {
+	// add the election to the merkle tree
+	s.accounts.tree.Set(types.ElectionKeyBytes, s.election.Marshal())
+
 	// add the tally to the merkle tree
 	s.accounts.tree.Set(types.TallyKeyBytes, s.tally.Marshal())
 
-	// save tally to db
-	s.db.Set(types.TallyKeyBytes, s.tally.Marshal())
-
 	// sync the accounts to the tree and save the tree
 	return s.accounts.Save()
 }
 
-func (s *State) Load() error {
-	// get the root hash
+// Check the db was left by a finished commit.
+// Returns the height of a commit that was interrupted before its batch was written, or -1.
+// The db is still at the last height, so the marker is cleared and the block can be replayed.
+// Returns an error if the db was left inconsistent
+func (s *State) CheckCommit() (int, error) {
+	interrupted := -1
+	if pendingBytes := s.db.Get(PendingKey); len(pendingBytes) > 0 {
+		if err := wire.ReadBinaryBytes(pendingBytes, &interrupted); err != nil {
+			return -1, err
+		}
+		s.db.DeleteSync(PendingKey)
+	}
+
 	rootHash := s.db.Get(StateKey)
+	if len(rootHash) == 0 {
+		// nothing has been committed
+		return interrupted, nil
+	}
+	var height int
+	heightBytes := s.db.Get(HeightKey)
+	if len(heightBytes) == 0 {
+		return interrupted, fmt.Errorf("Partial commit: state has root hash %X but no height", rootHash)
+	}
+	if err := wire.ReadBinaryBytes(heightBytes, &height); err != nil {
+		return interrupted, err
+	}
+	if heightRoot := s.db.Get(RootKey(height)); !bytes.Equal(heightRoot, rootHash) {
+		return interrupted, fmt.Errorf("Partial commit: root hash %X for height %d does not match the state's %X", heightRoot, height, rootHash)
+	}
+	return interrupted, nil
+}
 
-	// load the merkle tree
-	s.accounts.tree.Load(rootHash)
+// Returns true if a state has been committed to the db
+func (s *State) IsCommitted() bool {
+	return len(s.db.Get(StateKey)) > 0
+}
 
-	tallyBytes := s.db.Get(types.TallyKeyBytes)
-	if len(tallyBytes) == 0 {
-		return fmt.Errorf("New Tally not found in DB")
+// Load the latest committed state.
+// The merkle tree is the only source of truth for it.
+// Its root must be the one saved for its height
+func (s *State) Load() error {
+	// get the root hash and height
+	rootHash := s.db.Get(StateKey)
+	if len(rootHash) == 0 {
+		return fmt.Errorf("No state committed to the db")
 	}
-	err := s.tally.Unmarshal(tallyBytes)
-	if err != nil {
+	heightBytes := s.db.Get(HeightKey)
+	if len(heightBytes) == 0 {
+		return fmt.Errorf("State has root hash %X but no height", rootHash)
+	}
+	if err := wire.ReadBinaryBytes(heightBytes, &s.height); err != nil {
 		return err
 	}
+	if heightRoot := s.db.Get(RootKey(s.height)); !bytes.Equal(heightRoot, rootHash) {
+		return fmt.Errorf("State root hash %X does not match the root hash %X committed at height %d", rootHash, heightRoot, s.height)
+	}
+	return s.loadTree(rootHash)
+}
+
+// Load a copy of the state saved at the height.
+// It has no nonces, so is only suitable for queries
+func (s *State) LoadHeight(height int) (*State, error) {
+	rootHash := s.db.Get(RootKey(height))
+	if len(rootHash) == 0 {
+		return nil, fmt.Errorf("No state saved at height %d. It may have been pruned", height)
+	}
+	s2 := NewState(s.db)
+	s2.chainID = s.chainID
+	s2.height = height
+	if err := s2.loadTree(rootHash); err != nil {
+		return nil, err
+	}
+	return s2, nil
+}
+
+// load the merkle tree and the values cached from it.
+// go-merkle panics if nodes are missing from the db
+func (s *State) loadTree(rootHash []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Error loading the tree with root hash %X: %v", rootHash, r)
+		}
+	}()
+	s.accounts.tree.Load(rootHash)
+	s.appHash = rootHash
+
+	// the election is optional for states saved before it existed
+	if _, electionBytes, exists := s.accounts.tree.Get(types.ElectionKeyBytes); exists {
+		if err := s.election.Unmarshal(electionBytes); err != nil {
+			return err
+		}
+	}
 
 	// grab the tally bytes and unmarshal
 	_, tallyBytes, exists := s.accounts.tree.Get(types.TallyKeyBytes)
 	if !exists {
-		return fmt.Errorf("Tally not found in DB")
+		return fmt.Errorf("Tally not found in tree")
 	}
 	return s.tally.Unmarshal(tallyBytes)
 }
 
-//------------------------------------------------------------------------
+// Return a merkle proof of the key's value
+func (s *State) Proof(key []byte) ([]byte, error) {
+	_, proof, exists := s.accounts.tree.Proof(key)
+	if !exists {
+		return nil, fmt.Errorf("Key %X not found in tree", key)
+	}
+	return proof, nil
+}
 
-func (s *State) GetAccounts() ([]*types.PubAccount, error) {
-	var accs []*types.PubAccount
-	var iterErr error
-	stopped := s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
-		// this ignores the tally key
-		if len(key) != 32 {
-			return false
+// A merkle proof of the key's value if it's in the tree.
+// If it isn't, the proofs of the keys either side of where it would be,
+// which are next to each other in the tree, show that nothing is between them
+type KeyProof struct {
+	Key    []byte `json:"key"`
+	Exists bool   `json:"exists"`
+	Proof  []byte `json:"proof,omitempty"`
+
+	Left       []byte `json:"left,omitempty"` // nil if the key would be first
+	LeftProof  []byte `json:"left_proof,omitempty"`
+	Right      []byte `json:"right,omitempty"` // nil if the key would be last
+	RightProof []byte `json:"right_proof,omitempty"`
+}
+
+func (s *State) KeyProof(key []byte) (*KeyProof, error) {
+	tree := s.accounts.tree
+	kp := &KeyProof{Key: key}
+	index, _, exists := tree.Get(key)
+	if exists {
+		_, proof, _ := tree.Proof(key)
+		kp.Exists, kp.Proof = true, proof
+		return kp, nil
+	}
+	// the key would be at index
+	var err error
+	if index > 0 {
+		kp.Left, _ = tree.GetByIndex(index - 1)
+		if kp.LeftProof, err = s.Proof(kp.Left); err != nil {
+			return nil, err
 		}
-		var pubKey [32]byte
+	}
+	if index < tree.Size() {
+		kp.Right, _ = tree.GetByIndex(index)
+		if kp.RightProof, err = s.Proof(kp.Right); err != nil {
+			return nil, err
+		}
+	}
+	return kp, nil
+}
 
-		copy(pubKey[:], key)
-		acc := new(types.Account)
-		err := acc.Unmarshal(value)
-		if err != nil {
-			iterErr = err
-			return true
+// Voters can't vote or delegate once the election is closed,
+// so their nonces no longer protect against replays
+func (s *State) compactVoterNonces(batch dbm.Batch) error {
+	accs, err := s.GetAccounts()
+	if err != nil {
+		return err
+	}
+	for _, acc := range accs {
+		if acc.Account.Can(types.PermVote) {
+			if err := s.nonces.Compact(batch, acc.PubKey); err != nil {
+				return err
+			}
 		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------
+
+// Returns every account, with the changes not yet saved
+func (s *State) GetAccounts() ([]*types.PubAccount, error) {
+	var accs []*types.PubAccount
+	err := s.accounts.Iterate(func(pubKey types.PubKey, acc *types.Account) (stop bool) {
+		acc_ := *acc
 		accs = append(accs, &types.PubAccount{
-			PubKey:  types.PubKey(pubKey),
-			Account: acc,
+			PubKey:  pubKey,
+			Account: &acc_,
 		})
 		return false
 	})
-	if stopped {
-		return nil, iterErr
+	if err != nil {
+		return nil, err
 	}
 	return accs, nil
 }
@@ -0,0 +1,85 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Returns the validator set, sorted by pubkey
+func (s *State) GetValidators() ([]types.Validator, error) {
+	_, valsBytes, exists := s.accounts.tree.Get(types.ValidatorsKeyBytes)
+	if !exists || len(valsBytes) == 0 {
+		return nil, nil
+	}
+	return types.UnmarshalValidators(valsBytes)
+}
+
+// Set the validator set, as tendermint has it at genesis
+func (s *State) InitValidators(vals []types.Validator) {
+	s.setValidators(vals)
+}
+
+// Compute the validator set after the changes, without applying them.
+// Returns an error if a removed validator isn't in the set,
+// or if the set would have no power left
+func (s *State) prepareValidators(changes []types.Validator) ([]types.Validator, error) {
+	vals, err := s.GetValidators()
+	if err != nil {
+		return nil, err
+	}
+	valsByKey := make(map[types.PubKey]types.Validator)
+	for _, v := range vals {
+		valsByKey[v.PubKey] = v
+	}
+	for _, c := range changes {
+		if c.Power > 0 {
+			valsByKey[c.PubKey] = c
+			continue
+		}
+		if _, ok := valsByKey[c.PubKey]; !ok {
+			return nil, fmt.Errorf("Validator %X is not in the set", c.PubKey)
+		}
+		delete(valsByKey, c.PubKey)
+	}
+
+	vals = make([]types.Validator, 0, len(valsByKey))
+	var power uint64
+	for _, v := range valsByKey {
+		vals = append(vals, v)
+		power += v.Power
+	}
+	if power == 0 {
+		return nil, fmt.Errorf("Validator set would have no power")
+	}
+	return vals, nil
+}
+
+// Set the validators computed by prepareValidators.
+// The changes are returned to tendermint at the end of the block
+func (s *State) applyValidators(vals, changes []types.Validator) {
+	s.setValidators(vals)
+	s.validatorDiffs = append(s.validatorDiffs, changes...)
+}
+
+// Return the validator changes made since the last call
+func (s *State) PopValidatorDiffs() []types.Validator {
+	diffs := s.validatorDiffs
+	s.validatorDiffs = nil
+	return diffs
+}
+
+func (s *State) setValidators(vals []types.Validator) {
+	sort.Sort(validatorsByPubKey(vals))
+	s.accounts.tree.Set(types.ValidatorsKeyBytes, types.MarshalValidators(vals))
+}
+
+type validatorsByPubKey []types.Validator
+
+func (vs validatorsByPubKey) Len() int      { return len(vs) }
+func (vs validatorsByPubKey) Swap(i, j int) { vs[i], vs[j] = vs[j], vs[i] }
+func (vs validatorsByPubKey) Less(i, j int) bool {
+	return bytes.Compare(vs[i].PubKey.Bytes(), vs[j].PubKey.Bytes()) < 0
+}
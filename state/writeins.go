@@ -0,0 +1,172 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/tendermint/go-wire"
+	"github.com/tendermint/lil-voterin/types"
+)
+
+// Write-in counts are written directly to the merkle tree,
+// one key per normalized name, so each count has its own proof
+
+// Return the count for the normalized write-in name
+func (s *State) GetWriteIn(name string) (int64, error) {
+	_, countBytes, exists := s.accounts.tree.Get(types.WriteInKeyBytes(name))
+	if !exists || len(countBytes) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := wire.ReadBinaryBytes(countBytes, &count)
+	return count, err
+}
+
+// Set the count for the normalized write-in name.
+// A count of zero removes it
+func (s *State) SetWriteIn(name string, count int64) {
+	if count == 0 {
+		s.accounts.tree.Remove(types.WriteInKeyBytes(name))
+		return
+	}
+	s.accounts.tree.Set(types.WriteInKeyBytes(name), wire.BinaryBytes(count))
+}
+
+// Return all write-ins with votes, sorted by name
+func (s *State) GetWriteIns() ([]types.WriteInResult, error) {
+	var writeIns []types.WriteInResult
+	var iterErr error
+	stopped := s.accounts.tree.Iterate(func(key []byte, value []byte) (stop bool) {
+		if !bytes.HasPrefix(key, types.WriteInKeyPrefix) {
+			return false
+		}
+		var count int64
+		if err := wire.ReadBinaryBytes(value, &count); err != nil {
+			iterErr = err
+			return true
+		}
+		writeIns = append(writeIns, types.WriteInResult{
+			Name:  string(key[len(types.WriteInKeyPrefix):]),
+			Count: count,
+		})
+		return false
+	})
+	if stopped {
+		return nil, iterErr
+	}
+	return writeIns, nil
+}
+
+//------------------------------------------------------------------------
+// moving write-ins after the election closes
+
+// The result of a list of WriteInActions,
+// computed before anything is written to the state
+type writeInChanges struct {
+	election        *types.Election
+	writeIns        map[string]int64          // new count for each write-in
+	candidateCounts map[types.Candidate]int64 // added to the tally
+	outcome         *types.Outcome            // evaluated again with the moved votes
+}
+
+// Compute the changes from the actions. The election is modified
+// as candidates are promoted, so it should be a copy
+func (s *State) prepareWriteIns(election *types.Election, actions []types.WriteInAction) (*writeInChanges, error) {
+	changes := &writeInChanges{
+		election:        election,
+		writeIns:        make(map[string]int64),
+		candidateCounts: make(map[types.Candidate]int64),
+	}
+	getWriteIn := func(name string) (int64, error) {
+		if count, ok := changes.writeIns[name]; ok {
+			return count, nil
+		}
+		return s.GetWriteIn(name)
+	}
+
+	for _, action := range actions {
+		name := types.NormalizeWriteIn(action.WriteIn)
+		count, err := getWriteIn(name)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("Write-in %q has no votes", name)
+		}
+		changes.writeIns[name] = 0
+
+		// promote to a new candidate
+		if action.Into == "" {
+			c, err := election.AddCandidate(types.CandidateInfo{Name: name})
+			if err != nil {
+				return nil, err
+			}
+			changes.candidateCounts[c] += count
+			continue
+		}
+
+		// merge into a registered candidate
+		if c, ok := election.GetCandidate(action.Into); ok {
+			changes.candidateCounts[c] += count
+			continue
+		}
+
+		// merge into another write-in, which must have votes,
+		// so a misspelt candidate isn't made into a new write-in
+		into := types.NormalizeWriteIn(action.Into)
+		if into == name {
+			return nil, fmt.Errorf("Cannot merge write-in %q into itself", name)
+		}
+		intoCount, err := getWriteIn(into)
+		if err != nil {
+			return nil, err
+		}
+		if intoCount == 0 {
+			return nil, fmt.Errorf("%q is neither a registered candidate nor a write-in with votes", action.Into)
+		}
+		changes.writeIns[into] = intoCount + count
+	}
+
+	// the outcome recorded at close counted the write-ins for no candidate.
+	// no ballots are added, so turnout is the same
+	outcome, err := s.GetOutcome()
+	if err != nil {
+		return nil, err
+	}
+	if outcome != nil {
+		tally := s.GetTally().Copy()
+		tally.Grow(len(election.Candidates))
+		for c, count := range changes.candidateCounts {
+			tally.Counts[c] += count
+		}
+		changes.outcome = election.Evaluate(tally, outcome.Turnout, outcome.Eligible)
+		changes.outcome.ClosedBy = outcome.ClosedBy
+	}
+	return changes, nil
+}
+
+func (s *State) applyWriteIns(changes *writeInChanges) {
+	// grows the tally for promoted candidates
+	s.SetElection(changes.election)
+
+	// sort so the tree is deterministic
+	names := make([]string, 0, len(changes.writeIns))
+	for name, _ := range changes.writeIns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.SetWriteIn(name, changes.writeIns[name])
+	}
+
+	tally := s.GetTally()
+	for c, count := range changes.candidateCounts {
+		tally.Counts[c] += count
+	}
+	s.SetTally(tally)
+
+	if changes.outcome != nil {
+		s.setOutcome(changes.outcome)
+	}
+}
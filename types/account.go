@@ -2,47 +2,105 @@ package types
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/tendermint/go-crypto"
 	"github.com/tendermint/go-wire"
 )
 
 //------------------------------------------------------
-// pubkey is ed25519
+// pubkeys and signatures are any of go-crypto's types,
+// ed25519 or secp256k1, and are encoded with their type byte.
+// account pubkeys may also be multisig.
+// the zero PubKey has no key
 
-type (
-	PubKey    crypto.PubKeyEd25519
-	Signature crypto.SignatureEd25519
+type PubKey struct {
+	PubKey AccountPubKey `json:"unwrap"`
+}
+
+type Signature struct {
+	crypto.Signature `json:"unwrap"`
+}
+
+// The key types an account can have: go-crypto's, and the multisig key.
+// They're registered on this interface rather than on crypto.PubKey,
+// so go-crypto's registration, which tendermint uses for validators, is left alone
+type AccountPubKey interface {
+	crypto.PubKey
+}
+
+var _ = wire.RegisterInterface(
+	struct{ AccountPubKey }{},
+	wire.ConcreteType{crypto.PubKeyEd25519{}, crypto.PubKeyTypeEd25519},
+	wire.ConcreteType{crypto.PubKeySecp256k1{}, crypto.PubKeyTypeSecp256k1},
+	wire.ConcreteType{PubKeyMultisig{}, PubKeyTypeMultisig},
 )
 
-// Return the pubkey as a byte array
+func (p PubKey) IsZero() bool {
+	return p.PubKey == nil
+}
+
+// Return the pubkey's type byte followed by the key
 func (p PubKey) Bytes() []byte {
-	return crypto.PubKeyEd25519(p).Bytes()
+	if p.PubKey == nil {
+		return nil
+	}
+	return p.PubKey.Bytes()
 }
 
 // Verify the pubkey signed the msg
 func (p PubKey) VerifyBytes(msg []byte, sig Signature) bool {
-	return crypto.PubKeyEd25519(p).VerifyBytes(msg, crypto.SignatureEd25519(sig))
+	if p.PubKey == nil || sig.Signature == nil {
+		return false
+	}
+	return p.PubKey.VerifyBytes(msg, sig.Signature)
+}
+
+// Print the pubkey as the hex of its bytes, with any verb
+func (p PubKey) Format(f fmt.State, c rune) {
+	if c == 'x' {
+		fmt.Fprintf(f, "%x", p.Bytes())
+	} else {
+		fmt.Fprintf(f, "%X", p.Bytes())
+	}
+}
+
+func PubKeyFromBytes(pubKeyBytes []byte) (PubKey, error) {
+	var pubKey PubKey
+	err := wire.ReadBinaryBytes(pubKeyBytes, &pubKey)
+	return pubKey, err
+}
+
+// For pubkeys saved in the state, which must decode
+func BytesToPubKey(pubKeyBytes []byte) PubKey {
+	pubKey, err := PubKeyFromBytes(pubKeyBytes)
+	if err != nil {
+		panic(err)
+	}
+	return pubKey
 }
 
 //------------------------------------------------------
-// database keys for accessing accounts
+// database keys for accessing accounts.
+// the pubkey's type byte is part of the key
+
+// NOTE: other keys must not start with this
+var AccountKeyPrefix = []byte("ACCOUNT/")
 
 func AccountKeyBytes(pubKey PubKey) []byte {
-	return wire.BinaryBytes(pubKey)
+	return append(append([]byte{}, AccountKeyPrefix...), pubKey.Bytes()...)
 }
 
 func AccountKeyString(pubKey PubKey) string {
 	return string(AccountKeyBytes(pubKey))
 }
 
-func BytesToAccountKey(pubKeyBytes []byte) PubKey {
-	var pubKey PubKey
-	err := wire.ReadBinaryBytes(pubKeyBytes, &pubKey)
-	if err != nil {
-		panic(err)
-	}
-	return pubKey
+func IsAccountKey(key []byte) bool {
+	return bytes.HasPrefix(key, AccountKeyPrefix)
+}
+
+func BytesToAccountKey(key []byte) PubKey {
+	return BytesToPubKey(key[len(AccountKeyPrefix):])
 }
 
 //---------------------------------------
@@ -50,16 +108,27 @@ func BytesToAccountKey(pubKeyBytes []byte) PubKey {
 
 type AccountType uint
 
+// Each type is a role with the permissions in types/permissions.go
 const (
 	AccountTypeVoter AccountType = 1 + iota
 	AccountTypeAdmin
+	AccountTypeRegistrar  // adds voters
+	AccountTypeOfficer    // runs the election
+	AccountTypeAuditor    // reads private queries
+	AccountTypeTrustee    // submits decryption shares
+	AccountTypeSuperAdmin // adds admins and super-admins
 
+	// Deprecated: suspend or delete the account with an AccountAction
 	AccountTypeCorrupt = 100
 )
 
 type Account struct {
-	Sequence int         `json:"sequence"` // number of transactions committed
-	Type     AccountType `json:"type"`     // type for capabilities
+	Index       int         `json:"index"`                // set when the account is created
+	Sequence    int         `json:"sequence"`             // number of transactions committed
+	Type        AccountType `json:"type"`                 // type for capabilities
+	BallotQuota int         `json:"ballot_quota"`         // max ballots the voter may cast. 0 is unlimited
+	Suspension  *Suspension `json:"suspension,omitempty"` // suspended accounts can't make any tx
+	Tags        []string    `json:"tags,omitempty"`       // groups for election eligibility
 }
 
 func (acc *Account) Marshal() []byte {
@@ -72,15 +141,22 @@ func (acc *Account) Unmarshal(b []byte) error {
 	return *err
 }
 
-func NewAccount(typ AccountType) (crypto.PrivKeyEd25519, PubKey, *Account) {
-	priv := crypto.GenPrivKeyEd25519()
-	return priv,
-		PubKey(priv.PubKey().(crypto.PubKeyEd25519)),
-		&Account{Type: typ}
+// A new account with an ed25519 key
+func NewAccount(typ AccountType) (crypto.PrivKey, PubKey, *Account) {
+	return NewAccountWithKey(typ, crypto.GenPrivKeyEd25519())
+}
+
+func NewAccountWithKey(typ AccountType, priv crypto.PrivKey) (crypto.PrivKey, PubKey, *Account) {
+	return priv, PubFromPriv(priv), &Account{Type: typ}
 }
 
 func PubFromPriv(privKey crypto.PrivKey) PubKey {
-	return PubKey(privKey.PubKey().(crypto.PubKeyEd25519))
+	return PubKey{privKey.PubKey()}
+}
+
+// Sign the msg. For testing
+func SignBytes(privKey crypto.PrivKey, msg []byte) Signature {
+	return Signature{privKey.Sign(msg)}
 }
 
 //------------------------------------------
@@ -91,5 +167,7 @@ type PubAccount struct {
 }
 
 type GenesisState struct {
-	Accounts []PubAccount `json:"accounts"`
+	Accounts         []PubAccount      `json:"accounts"`
+	MultisigAccounts []MultisigAccount `json:"multisig_accounts"`
+	Election         *Election         `json:"election"`
 }
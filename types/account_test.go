@@ -3,15 +3,23 @@ package types
 import (
 	"bytes"
 	"testing"
+
+	"github.com/tendermint/go-crypto"
 )
 
 func TestAccountKey(t *testing.T) {
-	_, pub1, _ := NewAccount(AccountTypeVoter)
-	s := AccountKeyString(pub1)
-	pub2 := BytesToAccountKey([]byte(s))
+	_, ed, _ := NewAccount(AccountTypeVoter)
+	_, secp, _ := NewAccountWithKey(AccountTypeVoter, crypto.GenPrivKeySecp256k1())
+	for _, pub1 := range []PubKey{ed, secp} {
+		s := AccountKeyString(pub1)
+		pub2 := BytesToAccountKey([]byte(s))
 
-	if !bytes.Equal(pub1.Bytes(), pub2.Bytes()) {
-		t.Fatalf("Pubkeys dont match. %X and %X", pub1, pub2)
+		if !bytes.Equal(pub1.Bytes(), pub2.Bytes()) || pub1 != pub2 {
+			t.Fatalf("Pubkeys dont match. %X and %X", pub1, pub2)
+		}
+	}
+	if AccountKeyString(ed) == AccountKeyString(secp) {
+		t.Fatal("Account keys should differ by key type")
 	}
 }
 
@@ -30,3 +38,14 @@ func TestAccountMarshal(t *testing.T) {
 		acc.Sequence += 1
 	}
 }
+
+func TestValidatorPubKey(t *testing.T) {
+	_, ed, _ := NewAccount(AccountTypeVoter)
+	_, secp, _ := NewAccountWithKey(AccountTypeVoter, crypto.GenPrivKeySecp256k1())
+	if _, err := ValidatorFromTMSP(Validator{ed, 1}.TMSP()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidatorFromTMSP(Validator{secp, 1}.TMSP()); err == nil {
+		t.Fatal("expected a secp256k1 validator to be rejected")
+	}
+}
@@ -0,0 +1,178 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// database key for accessing the election
+
+// NOTE: must not start with AccountKeyPrefix
+var (
+	ElectionKeyString = "ELECTION"
+	ElectionKeyBytes  = []byte(ElectionKeyString)
+)
+
+//------------------------------------------
+// election holds the rules for the running election.
+// it is set in the genesis
+
+type Election struct {
+	// If true, a VoteTx replaces the ballots of the voter's
+	// previous VoteTx instead of adding to them
+	ReplaceBallots bool `json:"replace_ballots"`
+
+	// Set by a CloseTx, or at the end of the CloseHeight block. No more votes are accepted
+	Closed       bool `json:"closed"`
+	ClosedHeight int  `json:"closed_height"` // the block it was closed in
+
+	// Votes are accepted from the OpenHeight block.
+	// If CloseHeight is set, the election is closed at the end of that block.
+	// A rerun at the CloseHeight moves it on by the length of the first run
+	OpenHeight  int `json:"open_height"`
+	CloseHeight int `json:"close_height"`
+
+	// Registered candidates. A Candidate is an index into this list
+	Candidates []CandidateInfo `json:"candidates"`
+
+	// If true, ballots may include a write-in
+	AllowWriteIns bool `json:"allow_write_ins"`
+
+	// If true and none of the above wins when the election is closed,
+	// the tally is reset and the election stays open for a re-run
+	RerunOnNoneOfTheAbove bool `json:"rerun_on_nota"`
+	Reruns                int  `json:"reruns"`
+
+	// For the outcome to be valid, this fraction of voter accounts must vote
+	MinTurnout Fraction `json:"min_turnout"`
+	// and the winner must get this fraction of the ballots that weren't abstentions
+	MinWinningShare Fraction `json:"min_winning_share"`
+
+	// Max ballots in a VoteTx. 0 is unlimited
+	MaxBallotsPerTx int `json:"max_ballots_per_tx"`
+	// If true, a VoteTx with a bad ballot is rejected,
+	// instead of only the bad ballot not being counted
+	StrictBallots bool `json:"strict_ballots"`
+
+	// Admins who must approve a ProposeAdminTx, including the proposer.
+	// 0 doesn't allow proposals
+	ProposalApprovals int `json:"proposal_approvals"`
+
+	// If true, only the voters on the eligibility list and voters with one of its tags can vote.
+	// Set when a voter or tag is first added to the list, and only cleared by its option,
+	// so removing the last one leaves nobody eligible rather than everybody
+	Restricted bool `json:"restricted"`
+
+	// The number of voters and tags on the eligibility list, which is kept in the tree.
+	// Removing a voter doesn't remove the ballots they have already cast
+	EligibleVoters int `json:"eligible_voters"`
+	EligibleTags   int `json:"eligible_tags"`
+}
+
+func NewElection() *Election {
+	return &Election{}
+}
+
+func (e *Election) Copy() *Election {
+	e2 := *e
+	e2.Candidates = append([]CandidateInfo(nil), e.Candidates...)
+	return &e2
+}
+
+// Returns true if votes are accepted in the block at height
+func (e *Election) IsOpen(height int) bool {
+	return !e.Closed && height >= e.OpenHeight
+}
+
+// Register a new candidate. Names must be unique
+func (e *Election) AddCandidate(info CandidateInfo) (Candidate, error) {
+	if info.Name == "" {
+		return 0, fmt.Errorf("Candidate name cannot be empty")
+	}
+	for _, c := range e.Candidates {
+		if c.Name == info.Name {
+			return 0, fmt.Errorf("Candidate %s is already registered", info.Name)
+		}
+	}
+	e.Candidates = append(e.Candidates, info)
+	return Candidate(len(e.Candidates) - 1), nil
+}
+
+// Check every candidate on the ballot is registered.
+// Tally.AddBallot checks the rest
+func (e *Election) ValidateBallot(ballot Ballot) error {
+	for _, v := range ballot.Candidates {
+		if int(v) < 0 || int(v) >= len(e.Candidates) {
+			return fmt.Errorf("Candidate %d is not registered", v)
+		}
+	}
+	if ballot.WriteIn != "" {
+		if !e.AllowWriteIns {
+			return fmt.Errorf("Election does not allow write-ins")
+		}
+		name := NormalizeWriteIn(ballot.WriteIn)
+		if name == "" {
+			return fmt.Errorf("Write-in cannot be empty")
+		}
+		if len(name) > maxWriteInLength {
+			return fmt.Errorf("Write-in too long (%d). Max is %d", len(name), maxWriteInLength)
+		}
+		if len(ballot.Candidates)+1 > maxVotesPerBallot {
+			return fmt.Errorf("Too many candidates per ballot (%d plus a write-in). Max is %d", len(ballot.Candidates), maxVotesPerBallot)
+		}
+	}
+	return nil
+}
+
+// Returns the registered candidate with the name
+func (e *Election) GetCandidate(name string) (Candidate, bool) {
+	for i, c := range e.Candidates {
+		if c.Name == name {
+			return Candidate(i), true
+		}
+	}
+	return 0, false
+}
+
+// Label the counts in the tally with the candidates
+func (e *Election) Results(t *Tally) []CandidateResult {
+	results := make([]CandidateResult, len(e.Candidates))
+	for i, c := range e.Candidates {
+		results[i] = CandidateResult{
+			Candidate: Candidate(i),
+			Name:      c.Name,
+		}
+		if i < t.N() {
+			results[i].Count = t.Counts[i]
+		}
+	}
+	return results
+}
+
+func (e *Election) Marshal() []byte {
+	return wire.BinaryBytes(e)
+}
+
+func (e *Election) Unmarshal(b []byte) error {
+	r, n, err := bytes.NewBuffer(b), new(int), new(error)
+	wire.ReadBinary(e, r, 0, n, err)
+	return *err
+}
+
+//------------------------------------------
+// candidates are registered by admins
+
+type CandidateInfo struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PubKey      *PubKey `json:"pubkey,omitempty"` // optional
+}
+
+type CandidateResult struct {
+	Candidate Candidate `json:"candidate"`
+	Name      string    `json:"name"`
+	Count     int64     `json:"count"`
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"fmt"
+)
+
+//------------------------------------------
+// voter eligibility.
+// admins add voters and tagged groups of voters to the election's eligibility list.
+// each is kept under its own key in the tree, so a merkle proof of the key,
+// or of the keys either side of where it would be, shows whether a voter is on the list.
+// the election counts them and records whether it is restricted, so its proof shows both
+
+const maxTagLength = 64
+
+// Add the voters and tags to those eligible, or remove them
+type EligibilityAction struct {
+	Remove bool     `json:"remove,omitempty"`
+	Voters []PubKey `json:"voters,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// database keys for the voters and tags on the eligibility list
+var (
+	EligibleKeyPrefix    = []byte("ELIGIBLE/")
+	EligibleTagKeyPrefix = []byte("ELIGIBLETAG/")
+)
+
+func EligibleKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, EligibleKeyPrefix...), pubKey.Bytes()...)
+}
+
+func EligibleTagKeyBytes(tag string) []byte {
+	return append(append([]byte{}, EligibleTagKeyPrefix...), tag...)
+}
+
+// Returns true if the election restricts who can vote
+func (e *Election) IsRestricted() bool {
+	return e.Restricted
+}
+
+//------------------------------------------
+// tags group accounts, eg. by district.
+// they are set with the rest of the account by an AdminTx
+
+func ValidateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("Tag cannot be empty")
+	}
+	if len(tag) > maxTagLength {
+		return fmt.Errorf("Tag too long (%d). Max is %d", len(tag), maxTagLength)
+	}
+	return nil
+}
+
+func (acc *Account) HasTag(tag string) bool {
+	for _, t := range acc.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package types
+
+import (
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+//---------------------------------------
+// result codes specific to lil-voterin.
+// they start well above the codes used by tmsp
+
+const (
+	CodeTypeBallotQuotaExceeded tmsp.CodeType = 1001 + iota
+	CodeTypeBadCandidate
+	CodeTypeBadBallot
+	CodeTypeBadOption
+	CodeTypeBadAccountAction
+	CodeTypeBadEligibility
+)
+
+var (
+	ErrBallotQuotaExceeded = tmsp.NewError(CodeTypeBallotQuotaExceeded, "")
+	ErrBadCandidate        = tmsp.NewError(CodeTypeBadCandidate, "")
+	ErrBadBallot           = tmsp.NewError(CodeTypeBadBallot, "")
+	ErrBadOption           = tmsp.NewError(CodeTypeBadOption, "")
+	ErrBadAccountAction    = tmsp.NewError(CodeTypeBadAccountAction, "")
+	ErrBadEligibility      = tmsp.NewError(CodeTypeBadEligibility, "")
+)
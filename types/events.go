@@ -0,0 +1,43 @@
+package types
+
+import (
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// events are fired when a block is committed,
+// for anything that happened to the election in it
+
+const (
+	EventElectionOpened = "ElectionOpened"
+	EventElectionClosed = "ElectionClosed"
+	EventElectionRerun  = "ElectionRerun"
+
+	EventProposalExecuted = "ProposalExecuted" // data is the *Proposal
+)
+
+type Event struct {
+	Name string
+	Data EventData
+}
+
+// The data of an event, registered so subscribers
+// to the app rpc's websocket can be sent it
+type EventData interface{}
+
+const (
+	EventDataTypeElection = byte(0x01)
+	EventDataTypeProposal = byte(0x02)
+)
+
+var _ = wire.RegisterInterface(
+	struct{ EventData }{},
+	wire.ConcreteType{EventDataElection{}, EventDataTypeElection},
+	wire.ConcreteType{&Proposal{}, EventDataTypeProposal},
+)
+
+type EventDataElection struct {
+	Height   int       `json:"height"` // of the block the event happened in
+	Election *Election `json:"election"`
+	Outcome  *Outcome  `json:"outcome,omitempty"` // if the election was closed
+}
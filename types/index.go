@@ -0,0 +1,32 @@
+package types
+
+import (
+	"fmt"
+)
+
+//------------------------------------------
+// accounts are numbered in the order they are created,
+// so txs can name the signer by a short index instead of the pubkey
+
+// NOTE: must not start with AccountKeyPrefix
+var (
+	AccountCountKeyString = "ACCOUNTCOUNT"
+	AccountCountKeyBytes  = []byte(AccountCountKeyString)
+)
+
+var AccountIndexKeyPrefix = []byte("INDEX/")
+
+func AccountIndexKeyBytes(index int) []byte {
+	return append(append([]byte{}, AccountIndexKeyPrefix...), []byte(fmt.Sprintf("%d", index))...)
+}
+
+// A tx whose signer can be named by account index instead of pubkey.
+// The pubkey is always in the sign bytes,
+// so the same signature is valid in either form
+type IndexedTx interface {
+	Tx
+	// Returns the signer's index, 0 if none, and its pubkey, nil if only the index is set
+	Signer() (int, *PubKey)
+	// Set the pubkey of the account the index names
+	SetSigner(PubKey)
+}
@@ -0,0 +1,105 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// account lifecycle.
+// accounts are suspended, reinstated or deleted by an AdminTx,
+// and every change is kept in the account's history
+
+const (
+	AccountActionSuspend   = "suspend"
+	AccountActionReinstate = "reinstate"
+	AccountActionDelete    = "delete"
+
+	// recorded in the history of both keys by a RotateKeyTx
+	AccountActionRotate = "rotate"
+)
+
+type AccountAction struct {
+	PubKey PubKey `json:"pubkey"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"` // required to suspend
+	Until  int    `json:"until,omitempty"`  // last height of the suspension. 0 is until reinstated
+}
+
+type Suspension struct {
+	Reason string `json:"reason"`
+	Height int    `json:"height"` // when it was suspended
+	Until  int    `json:"until"`  // last height of the suspension. 0 is until reinstated
+}
+
+type AccountStatus string
+
+const (
+	AccountStatusActive    = AccountStatus("active")
+	AccountStatusSuspended = AccountStatus("suspended")
+)
+
+func ParseAccountStatus(s string) (AccountStatus, error) {
+	switch status := AccountStatus(s); status {
+	case "", AccountStatusActive, AccountStatusSuspended:
+		return status, nil
+	default:
+		return "", fmt.Errorf("Unknown account status %q", s)
+	}
+}
+
+// Returns true if the account is suspended in the block at height
+func (acc *Account) IsSuspended(height int) bool {
+	s := acc.Suspension
+	return s != nil && (s.Until == 0 || height <= s.Until)
+}
+
+func (acc *Account) Status(height int) AccountStatus {
+	if acc.IsSuspended(height) {
+		return AccountStatusSuspended
+	}
+	return AccountStatusActive
+}
+
+//------------------------------------------
+// database keys for accessing account histories and rotated keys
+
+// NOTE: must not start with AccountKeyPrefix
+var AccountHistoryKeyPrefix = []byte("HISTORY/")
+
+func AccountHistoryKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, AccountHistoryKeyPrefix...), pubKey.Bytes()...)
+}
+
+// the new key of a rotated account
+var RotatedKeyPrefix = []byte("ROTATED/")
+
+func RotatedKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, RotatedKeyPrefix...), pubKey.Bytes()...)
+}
+
+// an entry in the audit history of an account.
+// kept after the account is deleted
+type AccountEvent struct {
+	Height int    `json:"height"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Until  int    `json:"until,omitempty"`
+	By     PubKey `json:"by"` // the signer of the AdminTx, or the proposer
+
+	Key *PubKey `json:"key,omitempty"` // the other key in a rotation
+}
+
+type AccountHistory []AccountEvent
+
+func (h AccountHistory) Marshal() []byte {
+	return wire.BinaryBytes(h)
+}
+
+func (h *AccountHistory) Unmarshal(b []byte) error {
+	r, n, err := bytes.NewBuffer(b), new(int), new(error)
+	wire.ReadBinary(h, r, 0, n, err)
+	return *err
+}
@@ -0,0 +1,136 @@
+package types
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	. "github.com/tendermint/go-common"
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+const maxMultisigKeys = 8
+
+//------------------------------------------
+// a multisig account is controlled by a threshold of keys.
+// its pubkey is the sha256 of the threshold and keys,
+// so it fits wherever a PubKey does
+
+const PubKeyTypeMultisig = byte(0x10)
+
+// It can't verify signatures itself. See MultisigKey.VerifyBytes
+type PubKeyMultisig [32]byte
+
+func (p PubKeyMultisig) Bytes() []byte {
+	return wire.BinaryBytes(struct{ AccountPubKey }{p})
+}
+
+func (p PubKeyMultisig) KeyString() string {
+	return Fmt("%X", p[:])
+}
+
+func (p PubKeyMultisig) Address() []byte {
+	return p[:20]
+}
+
+func (p PubKeyMultisig) VerifyBytes(msg []byte, sig crypto.Signature) bool {
+	return false
+}
+
+func (p PubKeyMultisig) Equals(other crypto.PubKey) bool {
+	o, ok := other.(PubKeyMultisig)
+	return ok && o == p
+}
+
+type MultisigKey struct {
+	Threshold int      `json:"threshold"`
+	PubKeys   []PubKey `json:"pubkeys"`
+}
+
+type MultisigSignature struct {
+	PubKey    PubKey    `json:"pubkey"`
+	Signature Signature `json:"signature"`
+}
+
+// The pubkey of the multisig account
+func (m *MultisigKey) PubKey() PubKey {
+	return PubKey{PubKeyMultisig(sha256.Sum256(wire.BinaryBytes(m)))}
+}
+
+func (m *MultisigKey) Validate() error {
+	if len(m.PubKeys) == 0 || len(m.PubKeys) > maxMultisigKeys {
+		return fmt.Errorf("Multisig must have between 1 and %d keys, has %d", maxMultisigKeys, len(m.PubKeys))
+	}
+	if m.Threshold < 1 || m.Threshold > len(m.PubKeys) {
+		return fmt.Errorf("Multisig threshold %d must be between 1 and the number of keys %d", m.Threshold, len(m.PubKeys))
+	}
+	seen := make(map[PubKey]bool)
+	for _, pubKey := range m.PubKeys {
+		if pubKey.IsZero() {
+			return fmt.Errorf("Multisig keys cannot be empty")
+		}
+		if _, ok := pubKey.PubKey.(PubKeyMultisig); ok {
+			return fmt.Errorf("Multisig keys cannot be multisig")
+		}
+		if seen[pubKey] {
+			return fmt.Errorf("Duplicate multisig key %X", pubKey)
+		}
+		seen[pubKey] = true
+	}
+	return nil
+}
+
+// Returns true if at least the threshold of the keys signed the msg.
+// Each key is counted once
+func (m *MultisigKey) VerifyBytes(msg []byte, sigs []MultisigSignature) bool {
+	inSet := make(map[PubKey]bool)
+	for _, pubKey := range m.PubKeys {
+		inSet[pubKey] = true
+	}
+	signed := make(map[PubKey]bool)
+	for _, sig := range sigs {
+		if !inSet[sig.PubKey] || signed[sig.PubKey] {
+			continue
+		}
+		if sig.PubKey.VerifyBytes(msg, sig.Signature) {
+			signed[sig.PubKey] = true
+		}
+	}
+	return len(signed) >= m.Threshold
+}
+
+// Verify a tx signed by the pubkey, or by the multisig whose pubkey it is
+func verifyTxSignature(v Verifier, signBytes []byte, pubKey PubKey, signature Signature, multisig *MultisigKey, sigs []MultisigSignature) tmsp.Result {
+	if multisig == nil {
+		if !v.VerifyBytes(pubKey, signBytes, signature) {
+			return tmsp.ErrUnauthorized.AppendLog("Invalid signature")
+		}
+		return tmsp.OK
+	}
+	if err := multisig.Validate(); err != nil {
+		return tmsp.ErrEncodingError.AppendLog(err.Error())
+	}
+	if multisig.PubKey() != pubKey {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Multisig does not match pubkey %X", pubKey))
+	}
+	if !multisig.VerifyBytes(signBytes, sigs) {
+		return tmsp.ErrUnauthorized.AppendLog(Fmt("Fewer than %d valid signatures", multisig.Threshold))
+	}
+	return tmsp.OK
+}
+
+func signMultisig(signBytes []byte, priv crypto.PrivKey) MultisigSignature {
+	return MultisigSignature{
+		PubKey:    PubFromPriv(priv),
+		Signature: SignBytes(priv, signBytes),
+	}
+}
+
+//------------------------------------------
+// multisig accounts in the genesis
+
+type MultisigAccount struct {
+	Multisig *MultisigKey `json:"multisig"`
+	*Account `json:"account"`
+}
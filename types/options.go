@@ -0,0 +1,103 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+//------------------------------------------
+// options change how txs are executed, so they affect consensus.
+// they can be set with SetOption before the first block,
+// and only with an AdminTx after
+
+const (
+	OptionMaxBallotsPerTx = "max_ballots_per_tx"
+	OptionStrictBallots   = "strict_ballots"
+	OptionOpenHeight      = "open_height"
+	OptionCloseHeight     = "close_height"
+	OptionMinTurnout      = "min_turnout"       // a fraction, num/den
+	OptionMinWinningShare = "min_winning_share" // a fraction, num/den
+	OptionRestricted      = "restricted"        // false lets every voter vote again
+
+	OptionProposalApprovals = "proposal_approvals"
+)
+
+type Option struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func IsConsensusOption(key string) bool {
+	switch key {
+	case OptionMaxBallotsPerTx, OptionStrictBallots,
+		OptionOpenHeight, OptionCloseHeight, OptionProposalApprovals,
+		OptionMinTurnout, OptionMinWinningShare, OptionRestricted:
+		return true
+	}
+	return false
+}
+
+// Set the election rule for the option
+func (e *Election) SetOption(key, value string) error {
+	switch key {
+	case OptionMaxBallotsPerTx:
+		max, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", key, value, err)
+		}
+		if max < 0 {
+			return fmt.Errorf("%s cannot be negative", key)
+		}
+		e.MaxBallotsPerTx = max
+	case OptionStrictBallots:
+		strict, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", key, value, err)
+		}
+		e.StrictBallots = strict
+	case OptionOpenHeight, OptionCloseHeight:
+		height, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", key, value, err)
+		}
+		if height < 0 {
+			return fmt.Errorf("%s cannot be negative", key)
+		}
+		if key == OptionOpenHeight {
+			e.OpenHeight = height
+		} else {
+			e.CloseHeight = height
+		}
+		if e.CloseHeight > 0 && e.CloseHeight < e.OpenHeight {
+			return fmt.Errorf("Election would close at height %d before it opens at %d", e.CloseHeight, e.OpenHeight)
+		}
+	case OptionProposalApprovals:
+		approvals, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", key, value, err)
+		}
+		if approvals < 1 {
+			return fmt.Errorf("%s must be at least 1", key)
+		}
+		e.ProposalApprovals = approvals
+	case OptionMinTurnout, OptionMinWinningShare:
+		f, err := ParseFraction(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %v", key, err)
+		}
+		if key == OptionMinTurnout {
+			e.MinTurnout = f
+		} else {
+			e.MinWinningShare = f
+		}
+	case OptionRestricted:
+		restricted, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", key, value, err)
+		}
+		e.Restricted = restricted
+	default:
+		return fmt.Errorf("Unknown option %s", key)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// database key for accessing the outcome
+
+// NOTE: must not start with AccountKeyPrefix
+var (
+	OutcomeKeyString = "OUTCOME"
+	OutcomeKeyBytes  = []byte(OutcomeKeyString)
+)
+
+//------------------------------------------
+// fraction for turnout and threshold rules.
+// a zero denominator means no requirement
+
+type Fraction struct {
+	Num int64 `json:"num"`
+	Den int64 `json:"den"`
+}
+
+// Returns true if n/d is at least the fraction
+func (f Fraction) Met(n, d int64) bool {
+	if f.Den == 0 {
+		return true
+	}
+	return n*f.Den >= f.Num*d
+}
+
+func (f Fraction) IsZero() bool {
+	return f.Num == 0 && f.Den == 0
+}
+
+// A fraction must be between 0 and 1, with a positive denominator
+func (f Fraction) Validate() error {
+	if f.Den <= 0 {
+		return fmt.Errorf("Fraction %d/%d must have a positive denominator", f.Num, f.Den)
+	}
+	if f.Num < 0 || f.Num > f.Den {
+		return fmt.Errorf("Fraction %d/%d must be between 0 and 1", f.Num, f.Den)
+	}
+	return nil
+}
+
+// Parse a fraction written as num/den
+func ParseFraction(s string) (Fraction, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Fraction{}, fmt.Errorf("Fraction %q must be written as num/den", s)
+	}
+	num, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Fraction{}, fmt.Errorf("Invalid numerator in %q: %v", s, err)
+	}
+	den, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Fraction{}, fmt.Errorf("Invalid denominator in %q: %v", s, err)
+	}
+	f := Fraction{num, den}
+	return f, f.Validate()
+}
+
+//------------------------------------------
+// outcome of the election, recorded when it is closed
+
+type Outcome struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason"` // why the result isn't valid
+
+	// the winner is the candidate with the most votes
+	HasWinner   bool      `json:"has_winner"`
+	Winner      Candidate `json:"winner"`
+	WinnerVotes int64     `json:"winner_votes"`
+
+	Turnout  int `json:"turnout"`  // eligible voters with a ballot counted, directly or by delegation
+	Eligible int `json:"eligible"` // voter accounts that can vote in the election
+
+	ClosedBy PubKey `json:"closed_by"` // empty if closed on schedule
+}
+
+// Check the turnout and winning share rules.
+// An unset fraction has no requirement
+func (e *Election) ValidateRules() error {
+	if !e.MinTurnout.IsZero() {
+		if err := e.MinTurnout.Validate(); err != nil {
+			return fmt.Errorf("Invalid min_turnout: %v", err)
+		}
+	}
+	if !e.MinWinningShare.IsZero() {
+		if err := e.MinWinningShare.Validate(); err != nil {
+			return fmt.Errorf("Invalid min_winning_share: %v", err)
+		}
+	}
+	return nil
+}
+
+// Evaluate the tally against the election's rules.
+// Abstentions count towards turnout but not the winning share
+func (e *Election) Evaluate(t *Tally, turnout, eligible int) *Outcome {
+	o := &Outcome{
+		Turnout:  turnout,
+		Eligible: eligible,
+	}
+
+	// find the winner, if there's no tie
+	tied := false
+	for i, c := range t.Counts {
+		switch {
+		case c > o.WinnerVotes:
+			o.HasWinner, o.Winner, o.WinnerVotes = true, Candidate(i), c
+			tied = false
+		case c == o.WinnerVotes && c > 0:
+			tied = true
+		}
+	}
+
+	switch {
+	case !e.MinTurnout.Met(int64(turnout), int64(eligible)):
+		o.Reason = "Turnout is below the minimum"
+	case t.NoneOfTheAboveWins():
+		o.Reason = "None of the above won"
+	case !o.HasWinner:
+		o.Reason = "No votes for any candidate"
+	case tied:
+		o.Reason = "Tie for the most votes"
+	case !e.MinWinningShare.Met(o.WinnerVotes, t.Ballots-t.Abstain):
+		o.Reason = "Winner's share of the votes is below the minimum"
+	default:
+		o.Valid = true
+	}
+	if tied {
+		o.HasWinner = false
+	}
+	return o
+}
+
+func (o *Outcome) Marshal() []byte {
+	return wire.BinaryBytes(o)
+}
+
+func (o *Outcome) Unmarshal(b []byte) error {
+	r, n, err := bytes.NewBuffer(b), new(int), new(error)
+	wire.ReadBinary(o, r, 0, n, err)
+	return *err
+}
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	election := &Election{
+		Candidates:      make([]CandidateInfo, 3),
+		MinTurnout:      Fraction{1, 2},
+		MinWinningShare: Fraction{2, 3},
+	}
+
+	cases := []struct {
+		counts            []int64
+		ballots, abstain  int64
+		turnout, eligible int
+		valid             bool
+		winner            Candidate
+	}{
+		{[]int64{7, 3, 0}, 10, 0, 10, 20, true, 0},  // exactly half turned out
+		{[]int64{7, 3, 0}, 10, 0, 9, 20, false, 0},  // turnout too low
+		{[]int64{6, 4, 0}, 10, 0, 10, 10, false, 0}, // share too low
+		{[]int64{6, 3, 0}, 11, 2, 10, 10, true, 0},  // abstentions don't count against share
+		{[]int64{5, 5, 0}, 10, 0, 10, 10, false, 0}, // tie
+	}
+
+	for i, c := range cases {
+		tally := &Tally{Counts: c.counts, Ballots: c.ballots, Abstain: c.abstain}
+		o := election.Evaluate(tally, c.turnout, c.eligible)
+		if o.Valid != c.valid {
+			t.Fatalf("case %d: expected valid %v, got %v (%s)", i, c.valid, o.Valid, o.Reason)
+		}
+		if o.Valid && o.Winner != c.winner {
+			t.Fatalf("case %d: expected winner %d, got %d", i, c.winner, o.Winner)
+		}
+	}
+}
+
+func TestFractionOption(t *testing.T) {
+	election := NewElection()
+	if err := election.SetOption(OptionMinTurnout, "2/3"); err != nil {
+		t.Fatal(err)
+	}
+	if election.MinTurnout != (Fraction{2, 3}) {
+		t.Fatalf("expected min turnout 2/3, got %v", election.MinTurnout)
+	}
+	for _, value := range []string{"1/0", "-1/2", "3/2", "1/-2", "1", "a/b"} {
+		if err := election.SetOption(OptionMinWinningShare, value); err == nil {
+			t.Fatalf("expected an error setting %s to %s", OptionMinWinningShare, value)
+		}
+	}
+	if !election.MinWinningShare.IsZero() {
+		t.Fatalf("expected min winning share to be unset, got %v", election.MinWinningShare)
+	}
+
+	election.MinWinningShare = Fraction{1, 0}
+	if err := election.ValidateRules(); err == nil {
+		t.Fatal("expected an error for a zero denominator")
+	}
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"strings"
+)
+
+//------------------------------------------
+// permissions are granted by an account's type.
+// every tx is authorised by checking its account has the permissions it needs
+
+type Permission uint
+
+const (
+	PermVote              Permission = 1 << iota // cast ballots and delegate
+	PermRegisterVoters                           // add, change and disable voter accounts
+	PermManageRoles                              // add, change and disable accounts of other types, except super-admins
+	PermManageSuperAdmins                        // add, change and disable super-admin accounts
+	PermManageElection                           // register candidates, move write-ins, set options, close
+	PermManageValidators                         // change the validator set
+	PermFork                                     // send a ForkTx
+	PermQueryPrivate                             // read queries that aren't public
+	PermSubmitShares                             // submit decryption shares
+)
+
+var permissionNames = []string{
+	"vote",
+	"register_voters",
+	"manage_roles",
+	"manage_super_admins",
+	"manage_election",
+	"manage_validators",
+	"fork",
+	"query_private",
+	"submit_shares",
+}
+
+func (p Permission) String() string {
+	var names []string
+	for i, name := range permissionNames {
+		if p&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Admins keep every permission they had before roles.
+// Corrupt accounts and unknown types have none
+var rolePermissions = map[AccountType]Permission{
+	AccountTypeVoter:      PermVote,
+	AccountTypeRegistrar:  PermRegisterVoters,
+	AccountTypeOfficer:    PermManageElection,
+	AccountTypeAuditor:    PermQueryPrivate,
+	AccountTypeTrustee:    PermSubmitShares,
+	AccountTypeAdmin:      adminPermissions,
+	AccountTypeSuperAdmin: adminPermissions | PermManageSuperAdmins,
+}
+
+const adminPermissions = PermRegisterVoters | PermManageRoles | PermManageElection |
+	PermManageValidators | PermFork | PermQueryPrivate
+
+func (typ AccountType) Permissions() Permission {
+	return rolePermissions[typ]
+}
+
+// Returns true if the account has every one of the permissions
+func (acc *Account) Can(perm Permission) bool {
+	return acc.Type.Permissions()&perm == perm
+}
+
+// The permission needed to give an account the type,
+// or to change an account that has it
+func (typ AccountType) ManagePermission() Permission {
+	switch typ {
+	case AccountTypeVoter, AccountTypeCorrupt:
+		return PermRegisterVoters
+	case AccountTypeSuperAdmin:
+		return PermManageSuperAdmins
+	}
+	return PermManageRoles
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// the changes an AdminTx makes.
+// a proposal makes them once enough admins approve
+
+type AdminPayload struct {
+	PubAccounts []PubAccount    `json:"pub_accounts"`
+	Candidates  []CandidateInfo `json:"candidates,omitempty"`
+	WriteIns    []WriteInAction `json:"write_ins,omitempty"`
+	Options     []Option        `json:"options,omitempty"`
+
+	AccountActions []AccountAction     `json:"account_actions,omitempty"`
+	Eligibility    []EligibilityAction `json:"eligibility,omitempty"`
+}
+
+func (tx *AdminTx) Payload() *AdminPayload {
+	return &AdminPayload{
+		PubAccounts: tx.PubAccounts,
+		Candidates:  tx.Candidates,
+		WriteIns:    tx.WriteIns,
+		Options:     tx.Options,
+
+		AccountActions: tx.AccountActions,
+		Eligibility:    tx.Eligibility,
+	}
+}
+
+// Returns true if the payload changes the election
+func (p *AdminPayload) ChangesElection() bool {
+	return len(p.Candidates) > 0 || len(p.WriteIns) > 0 || len(p.Options) > 0 || len(p.Eligibility) > 0
+}
+
+// Check the payload without the state.
+// Every pubkey must have a key, since the zero one can't be saved
+func (p *AdminPayload) Validate() error {
+	for _, pubAcc := range p.PubAccounts {
+		if pubAcc.PubKey.IsZero() {
+			return fmt.Errorf("Account has no pubkey")
+		}
+		if pubAcc.Account == nil {
+			return fmt.Errorf("Account %X has no account to set", pubAcc.PubKey)
+		}
+	}
+	for _, action := range p.AccountActions {
+		if action.PubKey.IsZero() {
+			return fmt.Errorf("Account action %q has no pubkey", action.Action)
+		}
+	}
+	for _, action := range p.Eligibility {
+		for _, pubKey := range action.Voters {
+			if pubKey.IsZero() {
+				return fmt.Errorf("Eligible voter has no pubkey")
+			}
+		}
+	}
+	return nil
+}
+
+//------------------------------------------
+// database keys for accessing proposals
+
+// NOTE: must not start with AccountKeyPrefix
+var (
+	ProposalCountKeyString = "PROPOSALCOUNT"
+	ProposalCountKeyBytes  = []byte(ProposalCountKeyString)
+)
+
+var ProposalKeyPrefix = []byte("PROPOSAL/")
+
+func ProposalKeyBytes(id int) []byte {
+	return append(append([]byte{}, ProposalKeyPrefix...), []byte(fmt.Sprintf("%d", id))...)
+}
+
+//------------------------------------------
+// a proposal is made by a ProposeAdminTx and approved by ApproveTxs.
+// the proposer's approval counts.
+// once the election's ProposalApprovals have approved, the payload is executed.
+// proposals are kept after they execute or expire, so every admin decision is on chain
+
+type Proposal struct {
+	ID        int           `json:"id"`
+	Payload   *AdminPayload `json:"payload"`
+	Proposer  PubKey        `json:"proposer"`
+	Approvals []PubKey      `json:"approvals"`
+	Expires   int           `json:"expires"`  // last height approvals are accepted
+	Executed  int           `json:"executed"` // height it was executed at, or 0
+}
+
+func (p *Proposal) HasApproved(pubKey PubKey) bool {
+	for _, a := range p.Approvals {
+		if a == pubKey {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the proposal can still be approved in the block at height
+func (p *Proposal) IsPending(height int) bool {
+	return p.Executed == 0 && height <= p.Expires
+}
+
+func (p *Proposal) Marshal() []byte {
+	return wire.BinaryBytes(p)
+}
+
+func (p *Proposal) Unmarshal(b []byte) error {
+	r, n, err := bytes.NewBuffer(b), new(int), new(error)
+	wire.ReadBinary(p, r, 0, n, err)
+	return *err
+}
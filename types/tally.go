@@ -3,39 +3,136 @@ package types
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/tendermint/go-wire"
 )
 
-const maxVotesPerBallot = 5
+const (
+	maxVotesPerBallot = 5
+	maxWriteInLength  = 64
+)
 
 //------------------------------------------
 // database key for accessing tally
 
-// NOTE: must not be 32 bytes
+// NOTE: must not start with AccountKeyPrefix
 var (
 	TallyKeyString = "TALLYKEY"
 	TallyKeyBytes  = []byte(TallyKeyString)
 )
 
 //------------------------------------------
-// Candidate is an integer - 0-based
+// Candidate is an integer - 0-based.
+// It indexes the candidates registered in the election
 
 type Candidate int
 
 //------------------------------------------
-// ballot is a list of candidates voted for.
+// ballot is a list of candidates voted for,
+// or an abstention, or a vote for none of the above.
+
+type BallotChoice byte
+
+const (
+	BallotChoiceCandidates     BallotChoice = iota // vote for the listed candidates
+	BallotChoiceAbstain                            // counted, but for nobody
+	BallotChoiceNoneOfTheAbove                     // against every candidate
+)
 
 type Ballot struct {
-	Candidates []Candidate `json:"c"`
-	Source     string      `json:"s"`
+	Candidates []Candidate  `json:"c"`
+	Source     string       `json:"s"`
+	WriteIn    string       `json:"w,omitempty"` // counts as one of the votes on the ballot
+	Choice     BallotChoice `json:"x,omitempty"` // abstain and none of the above have no candidates
+}
+
+//------------------------------------------
+// database key for accessing the ballots last cast by a voter
+
+var BallotsKeyPrefix = []byte("BALLOTS/")
+
+func BallotsKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, BallotsKeyPrefix...), pubKey.Bytes()...)
+}
+
+//------------------------------------------
+// database key for accessing the number of ballots a voter has cast
+
+var BallotCountKeyPrefix = []byte("BALLOTCOUNT/")
+
+func BallotCountKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, BallotCountKeyPrefix...), pubKey.Bytes()...)
+}
+
+//------------------------------------------
+// database key for accessing the number of a voter's ballots in the tally.
+// voters with none have not voted, for turnout
+
+var CountedBallotsKeyPrefix = []byte("COUNTED/")
+
+func CountedBallotsKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, CountedBallotsKeyPrefix...), pubKey.Bytes()...)
+}
+
+//------------------------------------------
+// write-ins are counted by their normalized name
+
+// database key for accessing the count for a write-in
+var WriteInKeyPrefix = []byte("WRITEIN/")
+
+func WriteInKeyBytes(name string) []byte {
+	return append(append([]byte{}, WriteInKeyPrefix...), name...)
+}
+
+// Lower case with single spaces, so small differences
+// in how voters write a name don't split its count
+func NormalizeWriteIn(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+type WriteInResult struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// Move the count for a write-in once the election is closed.
+// If Into is empty, the write-in is registered as a new candidate.
+// If Into is the name of a registered candidate, the count is added to it.
+// Otherwise it is merged into the write-in named Into, which must have votes
+type WriteInAction struct {
+	WriteIn string `json:"write_in"`
+	Into    string `json:"into"`
 }
 
 //------------------------------------------
-// tally is a score for each candidate.
+// database key for accessing a voter's delegate
+
+var DelegateKeyPrefix = []byte("DELEGATE/")
+
+func DelegateKeyBytes(pubKey PubKey) []byte {
+	return append(append([]byte{}, DelegateKeyPrefix...), pubKey.Bytes()...)
+}
+
+func MarshalBallots(ballots []Ballot) []byte {
+	return wire.BinaryBytes(ballots)
+}
+
+func UnmarshalBallots(b []byte) ([]Ballot, error) {
+	var ballots []Ballot
+	err := wire.ReadBinaryBytes(b, &ballots)
+	return ballots, err
+}
+
+//------------------------------------------
+// tally is a score for each candidate,
+// and the number of abstentions and votes for none of the above.
 
 type Tally struct {
-	Counts []int64
+	Counts         []int64
+	Ballots        int64 // every ballot counted, including abstentions
+	Abstain        int64
+	NoneOfTheAbove int64
 }
 
 func NewTally(n int) *Tally {
@@ -47,6 +144,37 @@ func NewTally(n int) *Tally {
 // Add 1 to the tally for each unique index in the ballot
 // Returns an error if any element in a ballot is duplicated or greater than len(t)
 func (t *Tally) AddBallot(ballot Ballot) error {
+	return t.addBallot(ballot, 1)
+}
+
+// Subtract 1 from the tally for each unique index in the ballot.
+// Used to replace a ballot that was previously added
+func (t *Tally) RemoveBallot(ballot Ballot) error {
+	return t.addBallot(ballot, -1)
+}
+
+func (t *Tally) addBallot(ballot Ballot, sign int64) error {
+	switch ballot.Choice {
+	case BallotChoiceCandidates:
+		// an empty ballot is not an abstention
+		if len(ballot.Candidates) == 0 && ballot.WriteIn == "" {
+			return fmt.Errorf("Ballot has no candidates. Use the abstain choice to abstain")
+		}
+	case BallotChoiceAbstain, BallotChoiceNoneOfTheAbove:
+		if len(ballot.Candidates) > 0 || ballot.WriteIn != "" {
+			return fmt.Errorf("Ballot with choice %d cannot list candidates", ballot.Choice)
+		}
+		if ballot.Choice == BallotChoiceAbstain {
+			t.Abstain += sign
+		} else {
+			t.NoneOfTheAbove += sign
+		}
+		t.Ballots += sign
+		return nil
+	default:
+		return fmt.Errorf("Unknown ballot choice %d", ballot.Choice)
+	}
+
 	if len(ballot.Candidates) > maxVotesPerBallot {
 		return fmt.Errorf("Too many candidates per ballot (%d). Max is %d", len(ballot.Candidates), maxVotesPerBallot)
 	}
@@ -54,12 +182,6 @@ func (t *Tally) AddBallot(ballot Ballot) error {
 	l := len(t.Counts)
 	diff := make([]int64, l) // better to allocate once and zero?
 	for _, v := range ballot.Candidates {
-		// -1 is ignored the other votes in the ballot still count
-		// but this means we could have eg `[0,5,-1,10,-1]`
-		if int(v) == -1 {
-			continue
-		}
-
 		// check bounds and for duplicates
 		if int(v) < 0 {
 			return fmt.Errorf("Candidate cannot be negative")
@@ -74,17 +196,41 @@ func (t *Tally) AddBallot(ballot Ballot) error {
 		diff[v] += 1
 	}
 	for i, v := range diff {
-		t.Counts[i] += v // TODO: overflow
+		t.Counts[i] += sign * v // TODO: overflow
 	}
+	t.Ballots += sign
 	return nil
 }
 
+// Add zero counts until the tally has n candidates
+func (t *Tally) Grow(n int) {
+	for len(t.Counts) < n {
+		t.Counts = append(t.Counts, 0)
+	}
+}
+
 func (t *Tally) Copy() *Tally {
 	t2 := NewTally(t.N())
 	copy(t2.Counts, t.Counts)
+	t2.Ballots = t.Ballots
+	t2.Abstain = t.Abstain
+	t2.NoneOfTheAbove = t.NoneOfTheAbove
 	return t2
 }
 
+// Returns true if none of the above has more votes than every candidate
+func (t *Tally) NoneOfTheAboveWins() bool {
+	if t.NoneOfTheAbove == 0 {
+		return false
+	}
+	for _, c := range t.Counts {
+		if c >= t.NoneOfTheAbove {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *Tally) N() int {
 	return len(t.Counts)
 }
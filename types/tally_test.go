@@ -7,8 +7,8 @@ import (
 )
 
 func MakeTestBallots() (Ballot, Ballot) {
-	b1 := Ballot{[]Candidate{0, 2, 3}, RandStr(32)}
-	b2 := Ballot{[]Candidate{0, 1}, RandStr(32)}
+	b1 := Ballot{Candidates: []Candidate{0, 2, 3}, Source: RandStr(32)}
+	b2 := Ballot{Candidates: []Candidate{0, 1}, Source: RandStr(32)}
 	return b1, b2
 }
 
@@ -31,6 +31,66 @@ func TestTally(t *testing.T) {
 	checkExpected(t, tally, 4, 0)
 }
 
+func TestTallyRemoveBallot(t *testing.T) {
+	N := 5
+	tally := NewTally(N)
+	b1, b2 := MakeTestBallots()
+
+	if err := tally.AddBallot(b1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tally.AddBallot(b2); err != nil {
+		t.Fatal(err)
+	}
+	if err := tally.RemoveBallot(b1); err != nil {
+		t.Fatal(err)
+	}
+
+	checkExpected(t, tally, 0, 1)
+	checkExpected(t, tally, 1, 1)
+	checkExpected(t, tally, 2, 0)
+	checkExpected(t, tally, 3, 0)
+	checkExpected(t, tally, 4, 0)
+}
+
+func TestTallyChoices(t *testing.T) {
+	N := 5
+	tally := NewTally(N)
+	b1, _ := MakeTestBallots()
+	abstain := Ballot{Source: RandStr(32), Choice: BallotChoiceAbstain}
+	nota := Ballot{Source: RandStr(32), Choice: BallotChoiceNoneOfTheAbove}
+
+	for _, b := range []Ballot{b1, abstain, nota, nota} {
+		if err := tally.AddBallot(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tally.Ballots != 4 || tally.Abstain != 1 || tally.NoneOfTheAbove != 2 {
+		t.Fatalf("Got %d ballots, %d abstain, %d nota. expected 4, 1, 2", tally.Ballots, tally.Abstain, tally.NoneOfTheAbove)
+	}
+	if !tally.NoneOfTheAboveWins() {
+		t.Fatal("Expected none of the above to win")
+	}
+
+	// the old -1 sentinel is rejected
+	if err := tally.AddBallot(Ballot{Candidates: []Candidate{0, -1}}); err == nil {
+		t.Fatal("Expected error for negative candidate")
+	}
+	// abstentions can't vote for anyone
+	abstain.Candidates = []Candidate{1}
+	if err := tally.AddBallot(abstain); err == nil {
+		t.Fatal("Expected error for abstain ballot with candidates")
+	}
+	// neither can an empty ballot, which is not an abstention
+	if err := tally.AddBallot(Ballot{Source: RandStr(32)}); err == nil {
+		t.Fatal("Expected error for ballot with no candidates")
+	}
+	if tally.Ballots != 4 || tally.Abstain != 1 {
+		t.Fatalf("Got %d ballots, %d abstain. expected 4, 1", tally.Ballots, tally.Abstain)
+	}
+	checkExpected(t, tally, 1, 0)
+}
+
 func checkExpected(t *testing.T, tally *Tally, index, expected int64) {
 	if tally.Counts[index] != expected {
 		t.Fatalf("Got %d, expected %d", tally.Counts[index], expected)
@@ -19,11 +19,18 @@ const (
 	txTypeVote = 1 + iota
 	txTypeAdmin
 	txTypeFork
+	txTypeDelegate
+	txTypeClose
+	txTypeValidator
+	txTypeProposeAdmin
+	txTypeApprove
+	txTypeRotateKey
 )
 
 type Tx interface {
 	SignBytes() []byte
 	Validate() tmsp.Result
+	ValidateWith(Verifier) tmsp.Result // the signatures are checked by the verifier
 
 	// for testing
 	Sign(crypto.PrivKey)
@@ -34,6 +41,12 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&VoteTx{}, txTypeVote},
 	wire.ConcreteType{&AdminTx{}, txTypeAdmin},
 	wire.ConcreteType{&ForkTx{}, txTypeFork},
+	wire.ConcreteType{&DelegateTx{}, txTypeDelegate},
+	wire.ConcreteType{&CloseTx{}, txTypeClose},
+	wire.ConcreteType{&ValidatorTx{}, txTypeValidator},
+	wire.ConcreteType{&ProposeAdminTx{}, txTypeProposeAdmin},
+	wire.ConcreteType{&ApproveTx{}, txTypeApprove},
+	wire.ConcreteType{&RotateKeyTx{}, txTypeRotateKey},
 )
 
 func JSONBytes(tx Tx) []byte {
@@ -49,7 +62,8 @@ type VoteTx struct {
 	Ballots []Ballot `json:"ballots"`
 
 	Nonce     []byte    `json:"nonce"`
-	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	PubKey    *PubKey   `json:"pubkey,omitempty"` // or set Index
+	Index     int       `json:"index,omitempty"`  // the signer's account index, instead of the pubkey
 	Signature Signature `json:"signature,omitempty"`
 }
 
@@ -58,7 +72,7 @@ func (tx *VoteTx) SignBytes() []byte {
 	return wire.JSONBytes(struct {
 		Ballots []Ballot `json:"ballots"`
 		Nonce   []byte   `json:"nonce"`
-		Pubkey  PubKey   `json:"pubkey"`
+		Pubkey  *PubKey  `json:"pubkey"`
 	}{
 		tx.Ballots,
 		tx.Nonce,
@@ -67,8 +81,12 @@ func (tx *VoteTx) SignBytes() []byte {
 }
 
 func (tx *VoteTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *VoteTx) ValidateWith(v Verifier) tmsp.Result {
 	// NOTE
-	// pubkey length is enforced by type;
+	// pubkey type is enforced by decoding;
 	// tx byte length is enforced by maxTxSize;
 	// ballot is checked later in AddBallot
 
@@ -76,60 +94,116 @@ func (tx *VoteTx) Validate() tmsp.Result {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
 	}
 
+	if tx.PubKey == nil {
+		return tmsp.ErrEncodingError.AppendLog("Tx has no pubkey or account index")
+	}
+
 	// verify sig
-	if !tx.PubKey.VerifyBytes(tx.SignBytes(), tx.Signature) {
+	if !v.VerifyBytes(*tx.PubKey, tx.SignBytes(), tx.Signature) {
 		return tmsp.ErrUnauthorized.AppendLog("Invalid signature")
 	}
 	return tmsp.OK
 }
 
+func (tx *VoteTx) Signer() (int, *PubKey) {
+	return tx.Index, tx.PubKey
+}
+
+func (tx *VoteTx) SetSigner(pubKey PubKey) {
+	tx.PubKey = &pubKey
+}
+
 // Sign transaction. For testing
 func (tx *VoteTx) Sign(priv crypto.PrivKey) {
-	tx.Signature = Signature(priv.Sign(tx.SignBytes()).(crypto.SignatureEd25519))
+	tx.Signature = SignBytes(priv, tx.SignBytes())
 }
 
 //---------------------------------------
 // Admin Tx
 
 type AdminTx struct {
-	PubAccounts []PubAccount `json:"pub_accounts"`
+	PubAccounts []PubAccount    `json:"pub_accounts"`
+	Candidates  []CandidateInfo `json:"candidates,omitempty"` // registered in order
+	WriteIns    []WriteInAction `json:"write_ins,omitempty"`  // applied in order, after close
+	Options     []Option        `json:"options,omitempty"`    // consensus options, applied in order
+
+	AccountActions []AccountAction     `json:"account_actions,omitempty"` // for existing accounts, after PubAccounts
+	Eligibility    []EligibilityAction `json:"eligibility,omitempty"`     // applied in order
 
 	Nonce     []byte    `json:"nonce"`
-	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	PubKey    *PubKey   `json:"pubkey,omitempty"` // or set Index
+	Index     int       `json:"index,omitempty"`  // the signer's account index, instead of the pubkey
 	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
 }
 
 func (tx *AdminTx) SignBytes() []byte {
 	return wire.JSONBytes(struct {
-		Nonce       []byte       `json:"nonce"`
-		PubAccounts []PubAccount `json:"pub_accounts"`
-		Pubkey      PubKey       `json:"pubkey"`
+		AccountActions []AccountAction     `json:"account_actions"`
+		Candidates     []CandidateInfo     `json:"candidates"`
+		Eligibility    []EligibilityAction `json:"eligibility"`
+		Nonce          []byte              `json:"nonce"`
+		Options        []Option            `json:"options"`
+		PubAccounts    []PubAccount        `json:"pub_accounts"`
+		Pubkey         *PubKey             `json:"pubkey"`
+		WriteIns       []WriteInAction     `json:"write_ins"`
 	}{
+		tx.AccountActions,
+		tx.Candidates,
+		tx.Eligibility,
 		tx.Nonce,
+		tx.Options,
 		tx.PubAccounts,
 		tx.PubKey,
+		tx.WriteIns,
 	})
 }
 
 func (tx *AdminTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *AdminTx) ValidateWith(v Verifier) tmsp.Result {
 	// NOTE
-	// pubkey length is enforced by type;
+	// pubkey type is enforced by decoding;
 	// tx byte length is enforced by maxTxSize;
 
 	if len(tx.Nonce) > maxTxNonceSize {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
 	}
 
-	// verify sig
-	if !tx.PubKey.VerifyBytes(tx.SignBytes(), tx.Signature) {
-		return tmsp.ErrUnauthorized.AppendLog("Invalid signature")
+	if tx.PubKey == nil {
+		return tmsp.ErrEncodingError.AppendLog("Tx has no pubkey or account index")
 	}
-	return tmsp.OK
+
+	if err := tx.Payload().Validate(); err != nil {
+		return tmsp.ErrEncodingError.AppendLog(err.Error())
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), *tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+func (tx *AdminTx) Signer() (int, *PubKey) {
+	return tx.Index, tx.PubKey
+}
+
+func (tx *AdminTx) SetSigner(pubKey PubKey) {
+	tx.PubKey = &pubKey
 }
 
 // Sign transaction. For testing
 func (tx *AdminTx) Sign(priv crypto.PrivKey) {
-	tx.Signature = Signature(priv.Sign(tx.SignBytes()).(crypto.SignatureEd25519))
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+// Add a signature by one of the multisig keys. For testing
+func (tx *AdminTx) AddSignature(priv crypto.PrivKey) {
+	tx.Signatures = append(tx.Signatures, signMultisig(tx.SignBytes(), priv))
 }
 
 //---------------------------------------
@@ -138,15 +212,21 @@ func (tx *AdminTx) Sign(priv crypto.PrivKey) {
 type ForkTx struct {
 	Name      string    `json:"name"`
 	Nonce     []byte    `json:"nonce"`
-	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	PubKey    *PubKey   `json:"pubkey,omitempty"` // or set Index
+	Index     int       `json:"index,omitempty"`  // the signer's account index, instead of the pubkey
 	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
 }
 
 func (tx *ForkTx) SignBytes() []byte {
 	return wire.JSONBytes(struct {
-		Name   string `json:"name"`
-		Nonce  []byte `json:"nonce"`
-		Pubkey PubKey `json:"pubkey"`
+		Name   string  `json:"name"`
+		Nonce  []byte  `json:"nonce"`
+		Pubkey *PubKey `json:"pubkey"`
 	}{
 		tx.Name,
 		tx.Nonce,
@@ -155,22 +235,413 @@ func (tx *ForkTx) SignBytes() []byte {
 }
 
 func (tx *ForkTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *ForkTx) ValidateWith(v Verifier) tmsp.Result {
 	// NOTE
-	// pubkey length is enforced by type;
+	// pubkey type is enforced by decoding;
 	// tx byte length is enforced by maxTxSize;
 
 	if len(tx.Nonce) > maxTxNonceSize {
 		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
 	}
 
+	if tx.PubKey == nil {
+		return tmsp.ErrEncodingError.AppendLog("Tx has no pubkey or account index")
+	}
+
 	// verify sig
-	if !tx.PubKey.VerifyBytes(tx.SignBytes(), tx.Signature) {
+	return verifyTxSignature(v, tx.SignBytes(), *tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+func (tx *ForkTx) Signer() (int, *PubKey) {
+	return tx.Index, tx.PubKey
+}
+
+func (tx *ForkTx) SetSigner(pubKey PubKey) {
+	tx.PubKey = &pubKey
+}
+
+// Sign transaction. For testing
+func (tx *ForkTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+// Add a signature by one of the multisig keys. For testing
+func (tx *ForkTx) AddSignature(priv crypto.PrivKey) {
+	tx.Signatures = append(tx.Signatures, signMultisig(tx.SignBytes(), priv))
+}
+
+//---------------------------------------
+// Delegate Tx
+
+// Delegate the voter's vote to another voter.
+// A nil Delegate revokes the delegation
+type DelegateTx struct {
+	Delegate  *PubKey   `json:"delegate"`
+	Nonce     []byte    `json:"nonce"`
+	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature Signature `json:"signature,omitempty"`
+}
+
+func (tx *DelegateTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		Delegate *PubKey `json:"delegate"`
+		Nonce    []byte  `json:"nonce"`
+		Pubkey   PubKey  `json:"pubkey"`
+	}{
+		tx.Delegate,
+		tx.Nonce,
+		tx.PubKey,
+	})
+}
+
+func (tx *DelegateTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *DelegateTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	if tx.Delegate != nil && tx.Delegate.IsZero() {
+		return tmsp.ErrEncodingError.AppendLog("Delegate has no pubkey. Leave it out to remove the delegation")
+	}
+
+	if tx.Delegate != nil && *tx.Delegate == tx.PubKey {
+		return tmsp.ErrUnauthorized.AppendLog("Cannot delegate to self")
+	}
+
+	// verify sig
+	if !v.VerifyBytes(tx.PubKey, tx.SignBytes(), tx.Signature) {
 		return tmsp.ErrUnauthorized.AppendLog("Invalid signature")
 	}
 	return tmsp.OK
 }
 
 // Sign transaction. For testing
-func (tx *ForkTx) Sign(priv crypto.PrivKey) {
-	tx.Signature = Signature(priv.Sign(tx.SignBytes()).(crypto.SignatureEd25519))
+func (tx *DelegateTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+//---------------------------------------
+// Close Tx
+
+// Close the election. Delegated votes are added to the tally
+// and no more votes or delegations are accepted,
+// unless none of the above wins and the election is re-run
+type CloseTx struct {
+	Nonce     []byte    `json:"nonce"`
+	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
+}
+
+func (tx *CloseTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		Close  bool   `json:"close"`
+		Nonce  []byte `json:"nonce"`
+		Pubkey PubKey `json:"pubkey"`
+	}{
+		true,
+		tx.Nonce,
+		tx.PubKey,
+	})
+}
+
+func (tx *CloseTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *CloseTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+// Sign transaction. For testing
+func (tx *CloseTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+// Add a signature by one of the multisig keys. For testing
+func (tx *CloseTx) AddSignature(priv crypto.PrivKey) {
+	tx.Signatures = append(tx.Signatures, signMultisig(tx.SignBytes(), priv))
+}
+
+//---------------------------------------
+// Validator Tx
+
+// Add, remove, or change the power of validators.
+// The changes are returned to tendermint from EndBlock
+type ValidatorTx struct {
+	Validators []Validator `json:"validators"` // a power of 0 removes the validator
+
+	Nonce     []byte    `json:"nonce"`
+	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
+}
+
+func (tx *ValidatorTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		Nonce      []byte      `json:"nonce"`
+		Pubkey     PubKey      `json:"pubkey"`
+		Validators []Validator `json:"validators"`
+	}{
+		tx.Nonce,
+		tx.PubKey,
+		tx.Validators,
+	})
+}
+
+func (tx *ValidatorTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *ValidatorTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	if len(tx.Validators) == 0 {
+		return tmsp.ErrEncodingError.AppendLog("Tx has no validators")
+	}
+	seen := make(map[PubKey]bool)
+	for _, val := range tx.Validators {
+		if err := val.ValidatePubKey(); err != nil {
+			return tmsp.ErrEncodingError.AppendLog(err.Error())
+		}
+		if seen[val.PubKey] {
+			return tmsp.ErrEncodingError.AppendLog(Fmt("Duplicate validator %X", val.PubKey))
+		}
+		seen[val.PubKey] = true
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+// Sign transaction. For testing
+func (tx *ValidatorTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+// Add a signature by one of the multisig keys. For testing
+func (tx *ValidatorTx) AddSignature(priv crypto.PrivKey) {
+	tx.Signatures = append(tx.Signatures, signMultisig(tx.SignBytes(), priv))
+}
+
+//---------------------------------------
+// Propose Admin Tx
+
+// Propose the changes of an AdminTx, to be made once enough admins approve
+type ProposeAdminTx struct {
+	Payload AdminPayload `json:"payload"`
+	Expires int          `json:"expires"` // last height approvals are accepted
+
+	Nonce     []byte    `json:"nonce"`
+	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
+}
+
+func (tx *ProposeAdminTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		Expires int          `json:"expires"`
+		Nonce   []byte       `json:"nonce"`
+		Payload AdminPayload `json:"payload"`
+		Pubkey  PubKey       `json:"pubkey"`
+	}{
+		tx.Expires,
+		tx.Nonce,
+		tx.Payload,
+		tx.PubKey,
+	})
+}
+
+func (tx *ProposeAdminTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *ProposeAdminTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	if err := tx.Payload.Validate(); err != nil {
+		return tmsp.ErrEncodingError.AppendLog(err.Error())
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+// Sign transaction. For testing
+func (tx *ProposeAdminTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+//---------------------------------------
+// Approve Tx
+
+// Approve a proposal. The last approval needed executes it
+type ApproveTx struct {
+	ProposalID int `json:"proposal_id"`
+
+	Nonce     []byte    `json:"nonce"`
+	PubKey    PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature Signature `json:"signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
+}
+
+func (tx *ApproveTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		Nonce      []byte `json:"nonce"`
+		ProposalID int    `json:"proposal_id"`
+		Pubkey     PubKey `json:"pubkey"`
+	}{
+		tx.Nonce,
+		tx.ProposalID,
+		tx.PubKey,
+	})
+}
+
+func (tx *ApproveTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *ApproveTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+// Sign transaction. For testing
+func (tx *ApproveTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+//---------------------------------------
+// RotateKey Tx
+
+// Move an account to a new key. The old key can't be used again.
+// Signed by the old key and the new key,
+// or by an admin for a lost key, in which case NewSignature is ignored
+type RotateKeyTx struct {
+	OldPubKey PubKey `json:"old_pubkey"`
+	NewPubKey PubKey `json:"new_pubkey"`
+
+	Nonce        []byte    `json:"nonce"`
+	PubKey       PubKey    `json:"pubkey,omitempty"` // TODO: replace with AccountIndex
+	Signature    Signature `json:"signature,omitempty"`
+	NewSignature Signature `json:"new_signature,omitempty"`
+
+	// if the pubkey is a multisig account, the Signature is ignored
+	// and a threshold of its keys must sign
+	Multisig   *MultisigKey        `json:"multisig,omitempty"`
+	Signatures []MultisigSignature `json:"signatures,omitempty"`
+}
+
+func (tx *RotateKeyTx) SignBytes() []byte {
+	return wire.JSONBytes(struct {
+		NewPubKey PubKey `json:"new_pubkey"`
+		Nonce     []byte `json:"nonce"`
+		OldPubKey PubKey `json:"old_pubkey"`
+		Pubkey    PubKey `json:"pubkey"`
+	}{
+		tx.NewPubKey,
+		tx.Nonce,
+		tx.OldPubKey,
+		tx.PubKey,
+	})
+}
+
+// Returns true if the account's own key signed the rotation
+func (tx *RotateKeyTx) IsSelfSigned() bool {
+	return tx.PubKey == tx.OldPubKey
+}
+
+func (tx *RotateKeyTx) Validate() tmsp.Result {
+	return tx.ValidateWith(VerifyNow)
+}
+
+func (tx *RotateKeyTx) ValidateWith(v Verifier) tmsp.Result {
+	// NOTE
+	// pubkey type is enforced by decoding;
+	// tx byte length is enforced by maxTxSize;
+
+	if len(tx.Nonce) > maxTxNonceSize {
+		return tmsp.ErrBadNonce.AppendLog(Fmt("Nonce too big (%d). Max is %d", len(tx.Nonce), maxTxNonceSize))
+	}
+
+	if tx.OldPubKey.IsZero() || tx.NewPubKey.IsZero() {
+		return tmsp.ErrEncodingError.AppendLog("Tx needs the old and new pubkeys")
+	}
+
+	if tx.OldPubKey == tx.NewPubKey {
+		return tmsp.ErrUnauthorized.AppendLog("Cannot rotate to the same key")
+	}
+
+	// the new key must sign too, unless an admin replaces a lost key
+	if tx.IsSelfSigned() && !v.VerifyBytes(tx.NewPubKey, tx.SignBytes(), tx.NewSignature) {
+		return tmsp.ErrUnauthorized.AppendLog("Invalid signature by the new key")
+	}
+
+	// verify sig
+	return verifyTxSignature(v, tx.SignBytes(), tx.PubKey, tx.Signature, tx.Multisig, tx.Signatures)
+}
+
+// Sign transaction. For testing
+func (tx *RotateKeyTx) Sign(priv crypto.PrivKey) {
+	tx.Signature = SignBytes(priv, tx.SignBytes())
+}
+
+// Sign transaction with the new key. For testing
+func (tx *RotateKeyTx) SignNew(priv crypto.PrivKey) {
+	tx.NewSignature = SignBytes(priv, tx.SignBytes())
 }
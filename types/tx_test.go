@@ -13,7 +13,7 @@ func TestVoteTxSign(t *testing.T) {
 	tx := &VoteTx{
 		Ballots: []Ballot{b1, b2},
 		Nonce:   []byte{1, 2, 3},
-		PubKey:  pub,
+		PubKey:  &pub,
 	}
 	fmt.Printf("%X\n", priv.Bytes())
 	fmt.Println(string(tx.SignBytes()))
@@ -44,7 +44,7 @@ func TestAdminTxSign(t *testing.T) {
 			},
 		},
 		Nonce:  []byte{1, 2, 3},
-		PubKey: pub,
+		PubKey: &pub,
 	}
 	fmt.Printf("%X\n", priv.Bytes())
 	fmt.Println(string(tx.SignBytes()))
@@ -58,3 +58,93 @@ func TestAdminTxSign(t *testing.T) {
 		t.Fatal(r)
 	}
 }
+
+func TestMultisigAdminTx(t *testing.T) {
+	priv1, pub1, _ := NewAccount(AccountTypeAdmin)
+	priv2, pub2, _ := NewAccount(AccountTypeAdmin)
+	priv3, pub3, _ := NewAccount(AccountTypeAdmin)
+	multisig := &MultisigKey{Threshold: 2, PubKeys: []PubKey{pub1, pub2, pub3}}
+	msPub := multisig.PubKey()
+	tx := &AdminTx{
+		Nonce:    []byte{1},
+		PubKey:   &msPub,
+		Multisig: multisig,
+	}
+
+	// one signature isn't enough, even twice
+	tx.AddSignature(priv1)
+	tx.AddSignature(priv1)
+	if r := tx.Validate(); r.IsOK() {
+		t.Fatal("expected two signatures by the same key to fail")
+	}
+
+	tx.AddSignature(priv3)
+	if r := tx.Validate(); !r.IsOK() {
+		t.Fatal(r)
+	}
+
+	// a different set doesn't match the pubkey
+	tx.Multisig = &MultisigKey{Threshold: 1, PubKeys: []PubKey{pub1, pub2, pub3}}
+	if r := tx.Validate(); r.IsOK() {
+		t.Fatal("expected a multisig that doesn't match the pubkey to fail")
+	}
+
+	// a key outside the set doesn't count
+	tx.Multisig = multisig
+	tx.Signatures = nil
+	tx.AddSignature(priv1)
+	privOther, _, _ := NewAccount(AccountTypeAdmin)
+	tx.AddSignature(privOther)
+	if r := tx.Validate(); r.IsOK() {
+		t.Fatal("expected a signature from outside the multisig to fail")
+	}
+	tx.AddSignature(priv2)
+	if r := tx.Validate(); !r.IsOK() {
+		t.Fatal(r)
+	}
+}
+
+func TestZeroPubKeys(t *testing.T) {
+	priv, pub, _ := NewAccount(AccountTypeAdmin)
+	_, voter, _ := NewAccount(AccountTypeVoter)
+
+	// every key an AdminTx carries must have a key
+	for _, payload := range []AdminPayload{
+		{PubAccounts: []PubAccount{{PubKey{}, &Account{Type: AccountTypeVoter}}}},
+		{AccountActions: []AccountAction{{PubKey: PubKey{}, Action: AccountActionDelete}}},
+		{Eligibility: []EligibilityAction{{Voters: []PubKey{voter, PubKey{}}}}},
+	} {
+		tx := &AdminTx{
+			PubAccounts:    payload.PubAccounts,
+			AccountActions: payload.AccountActions,
+			Eligibility:    payload.Eligibility,
+			Nonce:          []byte{1},
+			PubKey:         &pub,
+		}
+		tx.Sign(priv)
+		if r := tx.Validate(); r.IsOK() {
+			t.Fatalf("expected a zero pubkey in %v to fail", payload)
+		}
+		propose := &ProposeAdminTx{Payload: payload, Nonce: []byte{1}, PubKey: pub}
+		propose.Sign(priv)
+		if r := propose.Validate(); r.IsOK() {
+			t.Fatalf("expected a zero pubkey in the proposal %v to fail", payload)
+		}
+	}
+
+	// an admin can't rotate a key to or from nothing
+	for _, rotate := range []*RotateKeyTx{
+		{OldPubKey: voter, Nonce: []byte{1}, PubKey: pub},
+		{NewPubKey: voter, Nonce: []byte{1}, PubKey: pub},
+	} {
+		rotate.Sign(priv)
+		if r := rotate.Validate(); r.IsOK() {
+			t.Fatalf("expected a rotation with a zero pubkey to fail")
+		}
+	}
+
+	delegate := &DelegateTx{Delegate: &PubKey{}, Nonce: []byte{1}, PubKey: voter}
+	if r := delegate.Validate(); r.IsOK() {
+		t.Fatal("expected a delegation to a zero pubkey to fail")
+	}
+}
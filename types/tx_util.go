@@ -30,7 +30,7 @@ func MakeVoteTx(tally *Tally, pub PubKey, nonce, nCandidates, nballots int) *Vot
 	return &VoteTx{
 		Ballots: ballots,
 		Nonce:   RandBytes(12),
-		PubKey:  pub,
+		PubKey:  &pub,
 	}
 }
 
@@ -43,6 +43,14 @@ func MakeAdminTx(signPub, modPub PubKey, accType AccountType, nonce []byte) *Adm
 			},
 		},
 		Nonce:  nonce,
-		PubKey: signPub,
+		PubKey: &signPub,
+	}
+}
+
+func MakeAccountActionTx(signPub PubKey, action AccountAction, nonce []byte) *AdminTx {
+	return &AdminTx{
+		AccountActions: []AccountAction{action},
+		Nonce:          nonce,
+		PubKey:         &signPub,
 	}
 }
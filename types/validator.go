@@ -0,0 +1,66 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/tendermint/go-crypto"
+	"github.com/tendermint/go-wire"
+	tmsp "github.com/tendermint/tmsp/types"
+)
+
+//------------------------------------------
+// database key for accessing the validator set
+
+// NOTE: must not start with AccountKeyPrefix
+var (
+	ValidatorsKeyString = "VALIDATORS"
+	ValidatorsKeyBytes  = []byte(ValidatorsKeyString)
+)
+
+//------------------------------------------
+// validators are set in the tendermint genesis,
+// and changed by a ValidatorTx. A power of 0 removes the validator
+
+type Validator struct {
+	PubKey PubKey `json:"pub_key"`
+	Power  uint64 `json:"power"`
+}
+
+// Convert the validator tendermint passes to InitChain
+func ValidatorFromTMSP(v *tmsp.Validator) (Validator, error) {
+	pubKey, err := crypto.PubKeyFromBytes(v.PubKey)
+	if err != nil {
+		return Validator{}, err
+	}
+	val := Validator{PubKey{pubKey}, v.Power}
+	if err := val.ValidatePubKey(); err != nil {
+		return Validator{}, err
+	}
+	return val, nil
+}
+
+// Tendermint consensus only uses ed25519 validator keys
+func (v Validator) ValidatePubKey() error {
+	if _, ok := v.PubKey.PubKey.(crypto.PubKeyEd25519); !ok {
+		return fmt.Errorf("Validator pubkey %X is not ed25519", v.PubKey)
+	}
+	return nil
+}
+
+// Convert the validator to return from EndBlock
+func (v Validator) TMSP() *tmsp.Validator {
+	return &tmsp.Validator{
+		PubKey: v.PubKey.Bytes(),
+		Power:  v.Power,
+	}
+}
+
+func MarshalValidators(vals []Validator) []byte {
+	return wire.BinaryBytes(vals)
+}
+
+func UnmarshalValidators(b []byte) ([]Validator, error) {
+	var vals []Validator
+	err := wire.ReadBinaryBytes(b, &vals)
+	return vals, err
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/tendermint/go-wire"
+)
+
+//------------------------------------------
+// tx signatures are checked through a Verifier,
+// either on their own or against the signatures already checked
+
+type Verifier interface {
+	// Returns false if the signature is bad
+	VerifyBytes(pubKey PubKey, msg []byte, sig Signature) bool
+}
+
+type verifyNow struct{}
+
+func (verifyNow) VerifyBytes(pubKey PubKey, msg []byte, sig Signature) bool {
+	return pubKey.VerifyBytes(msg, sig)
+}
+
+// Checks each signature as it's given
+var VerifyNow Verifier = verifyNow{}
+
+// Remembers the signatures that verified, so a tx checked in CheckTx
+// isn't checked again in AppendTx. A signature it hasn't seen is checked
+// as it's given, so the cache never changes whether a tx is valid.
+// The oldest signatures are forgotten once it's full.
+// Safe for concurrent use, so CheckTx and AppendTx can share one:
+// mtx guards seen and order, and isn't held while a signature is checked
+type SigCache struct {
+	mtx   sync.Mutex
+	size  int
+	seen  map[[sha256.Size]byte]struct{}
+	order [][sha256.Size]byte // oldest first
+}
+
+func NewSigCache(size int) *SigCache {
+	return &SigCache{
+		size: size,
+		seen: make(map[[sha256.Size]byte]struct{}),
+	}
+}
+
+func (c *SigCache) VerifyBytes(pubKey PubKey, msg []byte, sig Signature) bool {
+	if pubKey.IsZero() || sig.Signature == nil {
+		return false
+	}
+	key := sha256.Sum256(wire.BinaryBytes(struct {
+		PubKey []byte
+		Msg    []byte
+		Sig    []byte
+	}{pubKey.Bytes(), msg, sig.Bytes()}))
+
+	c.mtx.Lock()
+	_, ok := c.seen[key]
+	c.mtx.Unlock()
+	if ok {
+		return true
+	}
+
+	if !pubKey.VerifyBytes(msg, sig) {
+		return false
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.seen[key]; ok || c.size <= 0 {
+		return true
+	}
+	if len(c.order) >= c.size {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.seen[key] = struct{}{}
+	c.order = append(c.order, key)
+	return true
+}
+
+// Number of signatures remembered
+func (c *SigCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.order)
+}
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func makeSignedVoteTxs(n int) []*VoteTx {
+	txs := make([]*VoteTx, n)
+	for i := range txs {
+		priv, pub, _ := NewAccount(AccountTypeVoter)
+		b1, b2 := MakeTestBallots()
+		txs[i] = &VoteTx{
+			Ballots: []Ballot{b1, b2},
+			Nonce:   []byte{byte(i)},
+			PubKey:  &pub,
+		}
+		txs[i].Sign(priv)
+	}
+	return txs
+}
+
+func TestSigCache(t *testing.T) {
+	txs := makeSignedVoteTxs(10)
+
+	cache := NewSigCache(5)
+	for _, tx := range txs[:5] {
+		if r := tx.ValidateWith(cache); !r.IsOK() {
+			t.Fatal(r)
+		}
+	}
+	if cache.Len() != 5 {
+		t.Fatalf("Expected 5 signatures in the cache, got %d", cache.Len())
+	}
+
+	// a bad signature fails as it's given, and isn't remembered
+	sig := txs[7].Signature
+	txs[7].Signature = txs[3].Signature
+	if r := txs[7].ValidateWith(cache); r.IsOK() {
+		t.Fatal("Expected the bad signature to fail")
+	}
+	if cache.Len() != 5 {
+		t.Fatalf("Expected the bad signature not to be cached, got %d", cache.Len())
+	}
+
+	// the oldest are forgotten, but still verify
+	txs[7].Signature = sig
+	for _, tx := range txs {
+		if r := tx.ValidateWith(cache); !r.IsOK() {
+			t.Fatal(r)
+		}
+	}
+	if cache.Len() != 5 {
+		t.Fatalf("Expected the cache to stay at 5, got %d", cache.Len())
+	}
+}
+
+// Checking each signature as it's given, as CheckTx does with an empty cache
+func BenchmarkVerify(b *testing.B) {
+	txs := makeSignedVoteTxs(100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tx := range txs {
+			if r := tx.Validate(); !r.IsOK() {
+				b.Fatal(r)
+			}
+		}
+	}
+}
+
+// The same signatures spread over one goroutine per CPU.
+// This is parallel checking, not batched ed25519 verification, which go-crypto doesn't have,
+// so it only gains with more CPUs. The app doesn't do it, since AppendTx
+// must return each tx's result before the next tx is given
+func BenchmarkParallelVerify(b *testing.B) {
+	txs := makeSignedVoteTxs(100)
+	workers := runtime.NumCPU()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for j := w; j < len(txs); j += workers {
+					if r := txs[j].Validate(); !r.IsOK() {
+						b.Error(r)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+	}
+}
+
+// The same signatures once they're cached, as AppendTx sees txs already checked in CheckTx
+func BenchmarkSigCache(b *testing.B) {
+	txs := makeSignedVoteTxs(100)
+	cache := NewSigCache(len(txs))
+	for _, tx := range txs {
+		tx.ValidateWith(cache)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tx := range txs {
+			if r := tx.ValidateWith(cache); !r.IsOK() {
+				b.Fatal(r)
+			}
+		}
+	}
+}